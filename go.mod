@@ -1,7 +1,11 @@
 module github.com/parsiya/semgrep_go
 
-go 1.19
+go 1.20
 
-require github.com/olekukonko/tablewriter v0.0.5
+require (
+	github.com/olekukonko/tablewriter v0.0.5
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require github.com/mattn/go-runewidth v0.0.9 // indirect
@@ -0,0 +1,112 @@
+package output
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+
+	"github.com/parsiya/semgrep_go/output/codeclimate"
+	"github.com/parsiya/semgrep_go/output/enum"
+)
+
+// ToCodeClimate converts the results to CodeClimate issues for GitLab Code
+// Quality reports (`gl-code-quality-report.json`):
+//   - Categories come from the category metadata, e.g., security is Security
+//     and correctness is Bug Risk. Results with a vulnerability_class are
+//     Security. The default is Bug Risk.
+//   - ERROR is critical, WARNING is major, and INFO is minor.
+//   - The fingerprint is an MD5 hash of Extra.Fingerprint. If Semgrep didn't
+//     return one, the rule ID, path, and matched code are used instead. It
+//     doesn't change between pipelines if the code doesn't, so GitLab can
+//     track the issue.
+//
+// Ignored results are skipped.
+func (o Output) ToCodeClimate() []codeclimate.Issue {
+	issues := make([]codeclimate.Issue, 0, len(o.Results))
+	fingerprints := set{}
+	for _, r := range o.Results {
+		if r.Extra.IsIgnored != nil && *r.Extra.IsIgnored {
+			continue
+		}
+		issue := codeclimate.Issue{
+			Type:        "issue",
+			CheckName:   r.RuleID(),
+			Description: r.Message(),
+			Categories:  codeClimateCategories(r),
+			Location: codeclimate.Location{
+				Path:  filepath.ToSlash(r.FilePath()),
+				Lines: codeclimate.Lines{Begin: r.Start.Line, End: r.End.Line},
+			},
+			Severity:    codeClimateSeverity(r.Severity()),
+			Fingerprint: uniqueID(fingerprints, r, md5Hex),
+			EngineName:  "semgrep",
+		}
+		if refs := metadataValues(r, "references"); len(refs) > 0 {
+			body := r.Message() + "\n\nReferences:\n"
+			for _, ref := range refs {
+				body += "- " + ref + "\n"
+			}
+			issue.Content = &codeclimate.Content{Body: body}
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// Semgrep rule categories to CodeClimate categories.
+var codeClimateCategoryMap = map[string]string{
+	"security":         codeclimate.CategorySecurity,
+	"correctness":      codeclimate.CategoryBugRisk,
+	"performance":      codeclimate.CategoryPerformance,
+	"best-practice":    codeclimate.CategoryStyle,
+	"maintainability":  codeclimate.CategoryClarity,
+	"portability":      codeclimate.CategoryCompatibility,
+	"compatibility":    codeclimate.CategoryCompatibility,
+	"complexity":       codeclimate.CategoryComplexity,
+	"duplication":      codeclimate.CategoryDuplication,
+	"style":            codeclimate.CategoryStyle,
+	"caching":          codeclimate.CategoryPerformance,
+	"code-quality":     codeclimate.CategoryClarity,
+	"bug-risk":         codeclimate.CategoryBugRisk,
+	"readability":      codeclimate.CategoryClarity,
+	"deprecated-usage": codeclimate.CategoryCompatibility,
+}
+
+// codeClimateCategories returns the categories of a result.
+func codeClimateCategories(c CliMatch) []string {
+	var categories []string
+	seen := set{}
+	for _, cat := range metadataValues(c, "category") {
+		if mapped, ok := codeClimateCategoryMap[strings.ToLower(cat)]; ok && seen.add(mapped) {
+			categories = append(categories, mapped)
+		}
+	}
+	if len(metadataValues(c, "vulnerability_class")) > 0 && seen.add(codeclimate.CategorySecurity) {
+		categories = append(categories, codeclimate.CategorySecurity)
+	}
+	if len(categories) == 0 {
+		categories = []string{codeclimate.CategoryBugRisk}
+	}
+	return categories
+}
+
+// codeClimateSeverity converts a Semgrep severity to a CodeClimate severity.
+func codeClimateSeverity(sev enum.Severity) string {
+	switch sev {
+	case enum.SeverityCritical:
+		return codeclimate.SeverityBlocker
+	case enum.SeverityError, enum.SeverityHigh:
+		return codeclimate.SeverityCritical
+	case enum.SeverityWarning, enum.SeverityMedium:
+		return codeclimate.SeverityMajor
+	case enum.SeverityInfo, enum.SeverityLow:
+		return codeclimate.SeverityMinor
+	}
+	return codeclimate.SeverityInfo
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
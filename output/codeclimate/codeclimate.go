@@ -0,0 +1,58 @@
+// Package codeclimate has the types for CodeClimate issues. GitLab Code
+// Quality reports are a list of issues. See output.Output.ToCodeClimate.
+//
+// The spec is at
+// https://github.com/codeclimate/platform/blob/master/spec/analyzers/SPEC.md.
+package codeclimate
+
+// Issue categories.
+const (
+	CategoryBugRisk       = "Bug Risk"
+	CategoryClarity       = "Clarity"
+	CategoryCompatibility = "Compatibility"
+	CategoryComplexity    = "Complexity"
+	CategoryDuplication   = "Duplication"
+	CategoryPerformance   = "Performance"
+	CategorySecurity      = "Security"
+	CategoryStyle         = "Style"
+)
+
+// Issue severities.
+const (
+	SeverityInfo     = "info"
+	SeverityMinor    = "minor"
+	SeverityMajor    = "major"
+	SeverityCritical = "critical"
+	SeverityBlocker  = "blocker"
+)
+
+// Issue is a finding.
+type Issue struct {
+	Type        string   `json:"type"`
+	CheckName   string   `json:"check_name"`
+	Description string   `json:"description"`
+	Content     *Content `json:"content,omitempty"`
+	Categories  []string `json:"categories"`
+	Location    Location `json:"location"`
+	Severity    string   `json:"severity,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	// Used by GitLab to show the tool that found the issue.
+	EngineName string `json:"engine_name,omitempty"`
+}
+
+// Content is more information about an issue in Markdown.
+type Content struct {
+	Body string `json:"body"`
+}
+
+// Location is a file and its lines.
+type Location struct {
+	Path  string `json:"path"`
+	Lines Lines  `json:"lines"`
+}
+
+// Lines are the first and last lines of an issue. They start from 1.
+type Lines struct {
+	Begin int `json:"begin"`
+	End   int `json:"end,omitempty"`
+}
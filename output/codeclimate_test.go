@@ -0,0 +1,74 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/output/codeclimate"
+)
+
+func TestOutput_ToCodeClimate(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := out.ToCodeClimate()
+	if len(issues) != 67 {
+		t.Fatalf("got %d issues, want 67", len(issues))
+	}
+
+	fingerprints := map[string]bool{}
+	severities := map[string]int{}
+	for i, issue := range issues {
+		if fingerprints[issue.Fingerprint] {
+			t.Errorf("duplicate fingerprint %s", issue.Fingerprint)
+		}
+		fingerprints[issue.Fingerprint] = true
+		severities[issue.Severity]++
+		// All the rules in the fixture are security rules.
+		if !reflect.DeepEqual(issue.Categories, []string{codeclimate.CategorySecurity}) {
+			t.Errorf("issue %d: got categories %v", i, issue.Categories)
+		}
+		r := out.Results[i]
+		if issue.CheckName != r.RuleID() || issue.Location.Path != r.FilePath() ||
+			issue.Location.Lines.Begin != r.Start.Line || issue.Location.Lines.End != r.End.Line {
+			t.Errorf("issue %d: got %s at %+v", i, issue.CheckName, issue.Location)
+		}
+	}
+	want := map[string]int{codeclimate.SeverityCritical: 12, codeclimate.SeverityMajor: 52, codeclimate.SeverityMinor: 3}
+	if !reflect.DeepEqual(severities, want) {
+		t.Errorf("got severities %v, want %v", severities, want)
+	}
+
+	// Fingerprints are the same in the next pipeline.
+	if again := out.ToCodeClimate(); again[5].Fingerprint != issues[5].Fingerprint {
+		t.Errorf("the fingerprints changed between conversions")
+	}
+}
+
+func TestCodeClimateCategories(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		want     []string
+	}{
+		{"none", map[string]interface{}{}, []string{codeclimate.CategoryBugRisk}},
+		{"correctness", map[string]interface{}{"category": "correctness"}, []string{codeclimate.CategoryBugRisk}},
+		{"best-practice", map[string]interface{}{"category": "best-practice"}, []string{codeclimate.CategoryStyle}},
+		{"performance", map[string]interface{}{"category": "Performance"}, []string{codeclimate.CategoryPerformance}},
+		{
+			"vulnerability_class",
+			map[string]interface{}{"category": "correctness", "vulnerability_class": []interface{}{"Other"}},
+			[]string{codeclimate.CategoryBugRisk, codeclimate.CategorySecurity},
+		},
+		{"unknown", map[string]interface{}{"category": "unknown"}, []string{codeclimate.CategoryBugRisk}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CliMatch{Extra: CliMatchExtra{Metadata: tt.metadata}}
+			if got := codeClimateCategories(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("codeClimateCategories() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
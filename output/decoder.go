@@ -0,0 +1,507 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decoder reads Semgrep's JSON output from a stream and returns the results
+// one at a time. The whole output is never in memory, so it can be used for
+// very large outputs and directly on Semgrep's stdout.
+//
+//	dec := output.NewDecoder(r)
+//	for dec.Next() {
+//		hit := dec.Result()
+//		// ...
+//	}
+//	if err := dec.Err(); err != nil {
+//		// handle error
+//	}
+//	rest := dec.Output() // Everything except the results.
+//
+// The generated UnmarshalJSON methods decode every object twice to check the
+// required fields. The decoder uses private copies of the structs without
+// these methods and checks the required fields in a single pass. The errors
+// are the same as the ones from Deserialize.
+type Decoder struct {
+	dec   *json.Decoder
+	state decoderState
+	out   Output
+	cur   CliMatch
+	err   error
+	// Index of the next result.
+	index int
+	// Required top-level fields that were seen.
+	seen map[string]bool
+}
+
+type decoderState int
+
+const (
+	stateStart decoderState = iota
+	stateObject
+	stateResults
+	stateDone
+)
+
+// NewDecoder returns a decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r), seen: map[string]bool{}}
+}
+
+// Next decodes the next result. It returns false when there are no more
+// results or if there's an error. Check Err after it returns false. The
+// fields after `results` are decoded before Next returns false.
+func (d *Decoder) Next() bool {
+	if d.err != nil || d.state == stateDone {
+		return false
+	}
+	if d.state == stateStart {
+		if err := d.expect(json.Delim('{')); err != nil {
+			return d.fail(err)
+		}
+		d.state = stateObject
+	}
+
+	for {
+		if d.state == stateResults {
+			if d.dec.More() {
+				var m cliMatch
+				if err := d.dec.Decode(&m); err != nil {
+					return d.fail(fmt.Errorf("results[%d]: %w", d.index, err))
+				}
+				c, err := m.convert()
+				if err != nil {
+					return d.fail(fmt.Errorf("results[%d]: %w", d.index, err))
+				}
+				d.cur = c
+				d.index++
+				return true
+			}
+			// End of results.
+			if err := d.expect(json.Delim(']')); err != nil {
+				return d.fail(err)
+			}
+			d.state = stateObject
+		}
+
+		if !d.dec.More() {
+			if err := d.expect(json.Delim('}')); err != nil {
+				return d.fail(err)
+			}
+			d.state = stateDone
+			for _, field := range []string{"errors", "paths", "results"} {
+				if !d.seen[field] {
+					return d.fail(required("SemgrepOutputV1Jsonschema", field))
+				}
+			}
+			return false
+		}
+
+		tok, err := d.dec.Token()
+		if err != nil {
+			return d.fail(err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return d.fail(fmt.Errorf("expected a key, got %v", tok))
+		}
+		if key == "results" {
+			tok, err := d.dec.Token()
+			if err != nil {
+				return d.fail(err)
+			}
+			switch tok {
+			case nil:
+				// null is the same as a missing field.
+			case json.Delim('['):
+				d.seen[key] = true
+				d.state = stateResults
+			default:
+				return d.fail(fmt.Errorf("results: expected a list, got %v", tok))
+			}
+			continue
+		}
+		if err := d.field(key); err != nil {
+			return d.fail(fmt.Errorf("%s: %w", key, err))
+		}
+	}
+}
+
+// Result returns the result decoded by the last call to Next.
+func (d *Decoder) Result() CliMatch {
+	return d.cur
+}
+
+// Err returns the first error. It wraps io.ErrUnexpectedEOF if the output was
+// cut off.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Output returns the fields that have been decoded except the results. The
+// fields after `results` (e.g., `version` and `time`) are only there after
+// Next returns false.
+func (d *Decoder) Output() Output {
+	return d.out
+}
+
+// Decode decodes the rest of the output including the results.
+func (d *Decoder) Decode() (Output, error) {
+	results := []CliMatch{}
+	for d.Next() {
+		results = append(results, d.cur)
+	}
+	out := d.out
+	if d.seen["results"] {
+		out.Results = results
+	}
+	return out, d.err
+}
+
+func (d *Decoder) fail(err error) bool {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	d.err = fmt.Errorf("failed to deserialize Semgrep's output: %w", err)
+	return false
+}
+
+// expect reads a delimiter.
+func (d *Decoder) expect(delim json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// field decodes a top-level field other than `results`.
+func (d *Decoder) field(key string) error {
+	switch key {
+	case "errors":
+		var errs *[]CliError
+		if err := d.dec.Decode(&errs); err != nil {
+			return err
+		}
+		if errs != nil {
+			d.seen[key] = true
+			d.out.Errors = *errs
+		}
+		return nil
+	case "paths":
+		var p *scannedAndSkipped
+		if err := d.dec.Decode(&p); err != nil {
+			return err
+		}
+		if p == nil {
+			return nil
+		}
+		if p.Scanned == nil {
+			return required("ScannedAndSkipped", "scanned")
+		}
+		d.seen[key] = true
+		d.out.Paths = ScannedAndSkipped{Scanned: *p.Scanned, Skipped: p.Skipped}
+		return nil
+	case "skipped_rules":
+		return d.dec.Decode(&d.out.SkippedRules)
+	case "explanations":
+		return d.dec.Decode(&d.out.Explanations)
+	case "rules_by_engine":
+		return d.dec.Decode(&d.out.RulesByEngine)
+	case "engine_requested":
+		return d.dec.Decode(&d.out.EngineRequested)
+	case "time":
+		return d.dec.Decode(&d.out.Time)
+	case "version":
+		return d.dec.Decode(&d.out.Version)
+	}
+	// Skip unknown fields like Deserialize does.
+	var skip json.RawMessage
+	return d.dec.Decode(&skip)
+}
+
+// required returns the same error as the generated UnmarshalJSON methods.
+func required(typ, field string) error {
+	return fmt.Errorf("field %s in %s: required", field, typ)
+}
+
+// ----------
+
+// Copies of the generated structs without UnmarshalJSON. Required fields are
+// pointers so missing and null fields are nil.
+
+type scannedAndSkipped struct {
+	Scanned *[]Fpath        `json:"scanned"`
+	Skipped []SkippedTarget `json:"skipped"`
+}
+
+type cliMatch struct {
+	CheckId *RuleId        `json:"check_id"`
+	End     *position      `json:"end"`
+	Extra   *cliMatchExtra `json:"extra"`
+	Path    *Fpath         `json:"path"`
+	Start   *position      `json:"start"`
+}
+
+func (m *cliMatch) convert() (CliMatch, error) {
+	var c CliMatch
+	switch {
+	case m.CheckId == nil:
+		return c, required("CliMatch", "check_id")
+	case m.End == nil:
+		return c, required("CliMatch", "end")
+	case m.Extra == nil:
+		return c, required("CliMatch", "extra")
+	case m.Path == nil:
+		return c, required("CliMatch", "path")
+	case m.Start == nil:
+		return c, required("CliMatch", "start")
+	}
+	var err error
+	c.CheckId, c.Path = *m.CheckId, *m.Path
+	if c.Start, err = m.Start.convert(); err != nil {
+		return c, err
+	}
+	if c.End, err = m.End.convert(); err != nil {
+		return c, err
+	}
+	c.Extra, err = m.Extra.convert()
+	return c, err
+}
+
+type cliMatchExtra struct {
+	DataflowTrace   *matchDataflowTrace          `json:"dataflow_trace"`
+	EngineKind      CliMatchExtraEngineKind      `json:"engine_kind"`
+	ExtraExtra      CliMatchExtraExtraExtra      `json:"extra_extra"`
+	Fingerprint     *string                      `json:"fingerprint"`
+	Fix             *string                      `json:"fix"`
+	FixRegex        *fixRegex                    `json:"fix_regex"`
+	FixedLines      []string                     `json:"fixed_lines"`
+	IsIgnored       *bool                        `json:"is_ignored"`
+	Lines           *string                      `json:"lines"`
+	Message         *string                      `json:"message"`
+	Metadata        *CliMatchExtraMetadata       `json:"metadata"`
+	Metavars        map[string]metavarValue      `json:"metavars"`
+	ScaInfo         *ScaInfo                     `json:"sca_info"`
+	Severity        *CliMatchExtraSeverity       `json:"severity"`
+	ValidationState CliMatchExtraValidationState `json:"validation_state"`
+}
+
+func (m *cliMatchExtra) convert() (CliMatchExtra, error) {
+	var e CliMatchExtra
+	switch {
+	case m.Fingerprint == nil:
+		return e, required("CliMatchExtra", "fingerprint")
+	case m.Lines == nil:
+		return e, required("CliMatchExtra", "lines")
+	case m.Message == nil:
+		return e, required("CliMatchExtra", "message")
+	case m.Metadata == nil || *m.Metadata == nil:
+		return e, required("CliMatchExtra", "metadata")
+	case m.Severity == nil || *m.Severity == nil:
+		return e, required("CliMatchExtra", "severity")
+	}
+	e = CliMatchExtra{
+		EngineKind:      m.EngineKind,
+		ExtraExtra:      m.ExtraExtra,
+		Fingerprint:     *m.Fingerprint,
+		Fix:             m.Fix,
+		FixedLines:      m.FixedLines,
+		IsIgnored:       m.IsIgnored,
+		Lines:           *m.Lines,
+		Message:         *m.Message,
+		Metadata:        *m.Metadata,
+		ScaInfo:         m.ScaInfo,
+		Severity:        *m.Severity,
+		ValidationState: m.ValidationState,
+	}
+	if m.DataflowTrace != nil {
+		trace, err := m.DataflowTrace.convert()
+		if err != nil {
+			return e, err
+		}
+		e.DataflowTrace = &trace
+	}
+	if m.FixRegex != nil {
+		fr, err := m.FixRegex.convert()
+		if err != nil {
+			return e, err
+		}
+		e.FixRegex = &fr
+	}
+	if m.Metavars != nil {
+		e.Metavars = make(Metavars, len(m.Metavars))
+		for name, mv := range m.Metavars {
+			v, err := mv.convert()
+			if err != nil {
+				return e, err
+			}
+			e.Metavars[name] = v
+		}
+	}
+	return e, nil
+}
+
+type position struct {
+	Col    *int `json:"col"`
+	Line   *int `json:"line"`
+	Offset *int `json:"offset"`
+}
+
+func (p *position) convert() (Position, error) {
+	switch {
+	case p.Col == nil:
+		return Position{}, required("Position", "col")
+	case p.Line == nil:
+		return Position{}, required("Position", "line")
+	}
+	return Position{Col: *p.Col, Line: *p.Line, Offset: p.Offset}, nil
+}
+
+// optional converts an optional position.
+func (p *position) optional() (*Position, error) {
+	if p == nil {
+		return nil, nil
+	}
+	pos, err := p.convert()
+	return &pos, err
+}
+
+type metavarValue struct {
+	AbstractContent *string      `json:"abstract_content"`
+	End             *position    `json:"end"`
+	PropagatedValue *svalueValue `json:"propagated_value"`
+	Start           *position    `json:"start"`
+}
+
+func (m *metavarValue) convert() (MetavarValue, error) {
+	var v MetavarValue
+	switch {
+	case m.AbstractContent == nil:
+		return v, required("MetavarValue", "abstract_content")
+	case m.End == nil:
+		return v, required("MetavarValue", "end")
+	case m.Start == nil:
+		return v, required("MetavarValue", "start")
+	}
+	var err error
+	v.AbstractContent = *m.AbstractContent
+	if v.Start, err = m.Start.convert(); err != nil {
+		return v, err
+	}
+	if v.End, err = m.End.convert(); err != nil {
+		return v, err
+	}
+	if m.PropagatedValue != nil {
+		pv, err := m.PropagatedValue.convert()
+		if err != nil {
+			return v, err
+		}
+		v.PropagatedValue = &pv
+	}
+	return v, nil
+}
+
+type svalueValue struct {
+	SvalueAbstractContent *string   `json:"svalue_abstract_content"`
+	SvalueEnd             *position `json:"svalue_end"`
+	SvalueStart           *position `json:"svalue_start"`
+}
+
+func (s *svalueValue) convert() (SvalueValue, error) {
+	var v SvalueValue
+	if s.SvalueAbstractContent == nil {
+		return v, required("SvalueValue", "svalue_abstract_content")
+	}
+	var err error
+	v.SvalueAbstractContent = *s.SvalueAbstractContent
+	if v.SvalueStart, err = s.SvalueStart.optional(); err != nil {
+		return v, err
+	}
+	v.SvalueEnd, err = s.SvalueEnd.optional()
+	return v, err
+}
+
+type fixRegex struct {
+	Count       *int    `json:"count"`
+	Regex       *string `json:"regex"`
+	Replacement *string `json:"replacement"`
+}
+
+func (f *fixRegex) convert() (FixRegex, error) {
+	switch {
+	case f.Regex == nil:
+		return FixRegex{}, required("FixRegex", "regex")
+	case f.Replacement == nil:
+		return FixRegex{}, required("FixRegex", "replacement")
+	}
+	return FixRegex{Count: f.Count, Regex: *f.Regex, Replacement: *f.Replacement}, nil
+}
+
+type matchDataflowTrace struct {
+	IntermediateVars []matchIntermediateVar        `json:"intermediate_vars"`
+	TaintSink        MatchDataflowTraceTaintSink   `json:"taint_sink"`
+	TaintSource      MatchDataflowTraceTaintSource `json:"taint_source"`
+}
+
+func (m *matchDataflowTrace) convert() (MatchDataflowTrace, error) {
+	t := MatchDataflowTrace{TaintSink: m.TaintSink, TaintSource: m.TaintSource}
+	if m.IntermediateVars != nil {
+		t.IntermediateVars = make([]MatchIntermediateVar, len(m.IntermediateVars))
+		for i, iv := range m.IntermediateVars {
+			v, err := iv.convert()
+			if err != nil {
+				return t, err
+			}
+			t.IntermediateVars[i] = v
+		}
+	}
+	return t, nil
+}
+
+type matchIntermediateVar struct {
+	Content  *string   `json:"content"`
+	Location *location `json:"location"`
+}
+
+func (m *matchIntermediateVar) convert() (MatchIntermediateVar, error) {
+	switch {
+	case m.Content == nil:
+		return MatchIntermediateVar{}, required("MatchIntermediateVar", "content")
+	case m.Location == nil:
+		return MatchIntermediateVar{}, required("MatchIntermediateVar", "location")
+	}
+	loc, err := m.Location.convert()
+	return MatchIntermediateVar{Content: *m.Content, Location: loc}, err
+}
+
+type location struct {
+	End   *position `json:"end"`
+	Path  *Fpath    `json:"path"`
+	Start *position `json:"start"`
+}
+
+func (l *location) convert() (Location, error) {
+	var loc Location
+	switch {
+	case l.End == nil:
+		return loc, required("Location", "end")
+	case l.Path == nil:
+		return loc, required("Location", "path")
+	case l.Start == nil:
+		return loc, required("Location", "start")
+	}
+	var err error
+	loc.Path = *l.Path
+	if loc.Start, err = l.Start.convert(); err != nil {
+		return loc, err
+	}
+	loc.End, err = l.End.convert()
+	return loc, err
+}
@@ -0,0 +1,184 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"juice-shop":      juiceShopJSON,
+		"test-juice-shop": testBytes,
+	} {
+		t.Run(name, func(t *testing.T) {
+			want, err := Deserialize(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewDecoder(bytes.NewReader(data)).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode() is different from Deserialize()")
+			}
+		})
+	}
+}
+
+func TestDecoder_Next(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader(juiceShopJSON))
+	count := 0
+	for dec.Next() {
+		if dec.Result().RuleID() == "" {
+			t.Errorf("result %d has no rule ID", count)
+		}
+		// Fields before `results` are already decoded.
+		if len(dec.Output().Errors) != 29 {
+			t.Errorf("got %d errors before the results, want 29", len(dec.Output().Errors))
+		}
+		count++
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 67 {
+		t.Errorf("got %d results, want 67", count)
+	}
+
+	out := dec.Output()
+	if out.Results != nil {
+		t.Errorf("Output() has %d results, want none", len(out.Results))
+	}
+	if out.Version == nil || *out.Version != "1.42.0" {
+		t.Errorf("got version %v, want 1.42.0", out.Version)
+	}
+	if len(out.Paths.Scanned) != 977 {
+		t.Errorf("got %d scanned paths, want 977", len(out.Paths.Scanned))
+	}
+	// Next keeps returning false.
+	if dec.Next() {
+		t.Errorf("Next() returned true after the end")
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	const match = `{"check_id": "r", "path": "a.js", "start": {"line": 1, "col": 1},
+		"end": {"line": 1, "col": 2}, "extra": {"fingerprint": "f", "lines": "l",
+		"message": "m", "metadata": {}, "severity": "INFO"}}`
+
+	tests := []struct {
+		name    string
+		data    string
+		results int
+		want    string
+	}{
+		{
+			name:    "valid",
+			data:    `{"errors": [], "paths": {"scanned": []}, "results": [` + match + `, ` + match + `]}`,
+			results: 2,
+		},
+		{
+			name: "missing check_id",
+			data: `{"errors": [], "paths": {"scanned": []}, "results": [` +
+				strings.Replace(match, `"check_id": "r",`, "", 1) + `]}`,
+			want: "field check_id in CliMatch: required",
+		},
+		{
+			name: "null severity",
+			data: `{"errors": [], "paths": {"scanned": []}, "results": [` +
+				strings.Replace(match, `"INFO"`, "null", 1) + `]}`,
+			want: "field severity in CliMatchExtra: required",
+		},
+		{
+			name: "missing line",
+			data: `{"errors": [], "paths": {"scanned": []}, "results": [` +
+				strings.Replace(match, `"line": 1, "col": 2`, `"col": 2`, 1) + `]}`,
+			want: "field line in Position: required",
+		},
+		{
+			name:    "missing errors",
+			data:    `{"paths": {"scanned": []}, "results": [` + match + `]}`,
+			results: 1,
+			want:    "field errors in SemgrepOutputV1Jsonschema: required",
+		},
+		{
+			name: "null results",
+			data: `{"errors": [], "paths": {"scanned": []}, "results": null}`,
+			want: "field results in SemgrepOutputV1Jsonschema: required",
+		},
+		{
+			name: "missing scanned",
+			data: `{"errors": [], "paths": {}, "results": []}`,
+			want: "field scanned in ScannedAndSkipped: required",
+		},
+		{
+			name: "not an object",
+			data: `[]`,
+			want: "expected {",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.data))
+			results := 0
+			for dec.Next() {
+				results++
+			}
+			if results != tt.results {
+				t.Errorf("got %d results, want %d", results, tt.results)
+			}
+			err := dec.Err()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+			// Deserialize returns the same error for the required fields.
+			if strings.Contains(tt.want, "required") {
+				if _, err := Deserialize([]byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("Deserialize returned %v, want %q", err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_Truncated(t *testing.T) {
+	data := juiceShopJSON[:len(juiceShopJSON)/2]
+	_, err := NewDecoder(bytes.NewReader(data)).Decode()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func BenchmarkDeserialize(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(juiceShopJSON)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Deserialize(juiceShopJSON); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(juiceShopJSON)))
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoder(bytes.NewReader(juiceShopJSON))
+		for dec.Next() {
+		}
+		if err := dec.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
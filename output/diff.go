@@ -0,0 +1,196 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Semgrep returns this instead of the fingerprint and lines if the user is
+// not logged in.
+const requiresLogin = "requires login"
+
+// DiffResult is the result of comparing two Semgrep outputs.
+type DiffResult struct {
+	// Findings in head that are not in base.
+	New []CliMatch
+	// Findings in base that are not in head.
+	Fixed []CliMatch
+	// Findings in both. These are the head versions, so the lines and paths
+	// are the current ones.
+	Unchanged []CliMatch
+	// Files that were moved or renamed between base and head.
+	Moved []FileMove
+}
+
+// FileMove is a file that was moved or renamed.
+type FileMove struct {
+	From string
+	To   string
+}
+
+// Diff compares the results of a baseline scan with the current one. Findings
+// are matched in this order and each finding is only matched once:
+//
+//  1. Extra.Fingerprint, if Semgrep returned one.
+//  2. Rule ID, path, and a hash of the matched code. The hash ignores
+//     whitespace and line numbers, so it survives code moving up or down.
+//  3. Rule ID and the code hash for files that are only in base and files
+//     that are only in head. These are reported in Moved.
+func Diff(base, head Output) DiffResult {
+	d := differ{
+		base:     base.Results,
+		head:     head.Results,
+		baseUsed: make([]bool, len(base.Results)),
+		headUsed: make([]bool, len(head.Results)),
+	}
+
+	d.match(fingerprintKey, nil)
+	d.match(func(c CliMatch) (string, bool) {
+		return c.RuleID() + "\x00" + c.FilePath() + "\x00" + codeHash(c), true
+	}, nil)
+
+	basePaths, headPaths := outputPaths(base), outputPaths(head)
+	moves := map[FileMove]bool{}
+	d.match(func(c CliMatch) (string, bool) {
+		return c.RuleID() + "\x00" + codeHash(c), true
+	}, func(b, h CliMatch) bool {
+		from, to := b.FilePath(), h.FilePath()
+		if headPaths[from] || basePaths[to] {
+			return false
+		}
+		moves[FileMove{From: from, To: to}] = true
+		return true
+	})
+
+	var res DiffResult
+	for i, r := range d.base {
+		if !d.baseUsed[i] {
+			res.Fixed = append(res.Fixed, r)
+		}
+	}
+	for i, r := range d.head {
+		if d.headUsed[i] {
+			res.Unchanged = append(res.Unchanged, r)
+		} else {
+			res.New = append(res.New, r)
+		}
+	}
+	for m := range moves {
+		res.Moved = append(res.Moved, m)
+	}
+	sort.Slice(res.Moved, func(i, j int) bool {
+		if res.Moved[i].From != res.Moved[j].From {
+			return res.Moved[i].From < res.Moved[j].From
+		}
+		return res.Moved[i].To < res.Moved[j].To
+	})
+	return res
+}
+
+// differ keeps track of the matched findings.
+type differ struct {
+	base, head         []CliMatch
+	baseUsed, headUsed []bool
+}
+
+// match matches the unmatched findings with the same key. If the key function
+// returns false, the finding is skipped. If allowed is not nil, it's called
+// before two findings are matched.
+func (d *differ) match(key func(CliMatch) (string, bool), allowed func(b, h CliMatch) bool) {
+	// Unmatched base findings by key in order.
+	index := make(map[string][]int)
+	for i, r := range d.base {
+		if d.baseUsed[i] {
+			continue
+		}
+		if k, ok := key(r); ok {
+			index[k] = append(index[k], i)
+		}
+	}
+
+	for j, r := range d.head {
+		if d.headUsed[j] {
+			continue
+		}
+		k, ok := key(r)
+		if !ok {
+			continue
+		}
+		candidates := index[k]
+		for n, i := range candidates {
+			if allowed != nil && !allowed(d.base[i], r) {
+				continue
+			}
+			d.baseUsed[i], d.headUsed[j] = true, true
+			index[k] = append(candidates[:n:n], candidates[n+1:]...)
+			break
+		}
+	}
+}
+
+// fingerprintKey returns the fingerprint if Semgrep returned one.
+func fingerprintKey(c CliMatch) (string, bool) {
+	fp := c.Extra.Fingerprint
+	return fp, fp != "" && fp != requiresLogin
+}
+
+// findingKey identifies a finding between scans. It's the fingerprint or the
+// rule ID, path, and a hash of the matched code.
+func findingKey(c CliMatch) string {
+	if fp, ok := fingerprintKey(c); ok {
+		return fp
+	}
+	return c.RuleID() + "\x00" + c.FilePath() + "\x00" + codeHash(c)
+}
+
+// uniqueID returns hash(findingKey(c)) and adds it to seen. Identical findings
+// have the same key, so a counter is added to the key until the id is not in
+// seen.
+func uniqueID(seen set, c CliMatch, hash func(string) string) string {
+	key := findingKey(c)
+	id := hash(key)
+	for n := 1; !seen.add(id); n++ {
+		id = hash(key + "\x00" + strconv.Itoa(n))
+	}
+	return id
+}
+
+// codeHash returns a hash of the matched code without whitespace. If Semgrep
+// didn't return the code, the message and metavariable values are used.
+func codeHash(c CliMatch) string {
+	var code string
+	if c.Extra.Lines != "" && c.Extra.Lines != requiresLogin {
+		lines := strings.Split(c.Extra.Lines, "\n")
+		for i := range lines {
+			lines[i] = strings.Join(strings.Fields(lines[i]), " ")
+		}
+		code = strings.Join(lines, "\n")
+	} else {
+		names := make([]string, 0, len(c.Extra.Metavars))
+		for name := range c.Extra.Metavars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		code = c.Message()
+		for _, name := range names {
+			code += "\x00" + name + "=" + c.Extra.Metavars[name].AbstractContent
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// outputPaths returns the scanned paths and the paths with findings.
+func outputPaths(o Output) map[string]bool {
+	paths := make(map[string]bool)
+	for _, p := range o.Paths.Scanned {
+		paths[string(p)] = true
+	}
+	for _, r := range o.Results {
+		paths[r.FilePath()] = true
+	}
+	return paths
+}
@@ -0,0 +1,99 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		fingerprint bool
+	}{
+		{"fingerprint", true},
+		{"requires login", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := Deserialize(juiceShopJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			head, err := Deserialize(juiceShopJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.fingerprint {
+				for i := range base.Results {
+					base.Results[i].Extra.Fingerprint = requiresLogin
+				}
+				for i := range head.Results {
+					head.Results[i].Extra.Fingerprint = requiresLogin
+				}
+			}
+
+			const (
+				shifted = "juice-shop/routes/fileUpload.ts"
+				oldPath = "juice-shop/routes/login.ts"
+				newPath = "juice-shop/routes/auth/login.ts"
+			)
+			for i, r := range head.Results {
+				switch r.FilePath() {
+				case shifted:
+					// Code was added above the findings.
+					head.Results[i].Start.Line += 10
+					head.Results[i].End.Line += 10
+					head.Results[i].Extra.Lines = "  " + r.Extra.Lines + "  "
+				case oldPath:
+					// The fingerprint has the path.
+					head.Results[i].Path = newPath
+					if tt.fingerprint {
+						head.Results[i].Extra.Fingerprint = "renamed"
+					}
+				}
+			}
+			for i, p := range head.Paths.Scanned {
+				if p == oldPath {
+					head.Paths.Scanned[i] = newPath
+				}
+			}
+
+			// Fix two findings and add a new one.
+			fixed := head.Results[:2]
+			head.Results = head.Results[2:]
+			added := head.Results[3]
+			added.Extra.Lines = "eval(req.body.code)"
+			added.Extra.Fingerprint = "new"
+			head.Results = append(head.Results, added)
+
+			got := Diff(base, head)
+			if !reflect.DeepEqual(got.New, []CliMatch{added}) {
+				t.Errorf("Diff() New = %v, want %v", got.New, added)
+			}
+			if !reflect.DeepEqual(got.Fixed, fixed) {
+				t.Errorf("Diff() Fixed = %v, want %v", got.Fixed, fixed)
+			}
+			if len(got.Unchanged) != 65 {
+				t.Errorf("Diff() got %d unchanged findings, want 65", len(got.Unchanged))
+			}
+			wantMoved := []FileMove{{From: oldPath, To: newPath}}
+			if !reflect.DeepEqual(got.Moved, wantMoved) {
+				t.Errorf("Diff() Moved = %v, want %v", got.Moved, wantMoved)
+			}
+		})
+	}
+}
+
+func TestDiff_Empty(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Diff(Output{}, out)
+	if len(got.New) != 67 || len(got.Fixed) != 0 || len(got.Unchanged) != 0 || len(got.Moved) != 0 {
+		t.Errorf("Diff() with an empty base = %d new, %d fixed", len(got.New), len(got.Fixed))
+	}
+	got = Diff(out, out)
+	if len(got.Unchanged) != 67 || len(got.New) != 0 || len(got.Fixed) != 0 {
+		t.Errorf("Diff() with the same output = %d unchanged", len(got.Unchanged))
+	}
+}
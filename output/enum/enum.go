@@ -0,0 +1,432 @@
+// Package enum has typed enums for the Semgrep output fields that are
+// `interface{}` in the generated structs.
+//
+// The JSON schema is generated from an ATD file. ATD variants without a
+// payload are strings (e.g., "ERROR") and variants with a payload are tagged
+// arrays (e.g., ["PartialParsing", [...]]). The parse functions accept both,
+// the object form ({"PartialParsing": [...]}), and the values decoded by
+// encoding/json into `interface{}`. Values that are not in this package are
+// kept as-is so nothing is lost when Semgrep adds a new variant.
+//
+// Each type has an Unknown constant. OrUnknown returns it for values that are
+// not in this package (and for JSON null) so they can be handled in a switch,
+// and Raw returns the value as it was in the output:
+//
+//	switch hit.Severity().OrUnknown() {
+//	case enum.SeverityError:
+//		// ...
+//	case enum.SeverityUnknown:
+//		log.Printf("new severity: %s", hit.Severity().Raw())
+//	}
+package enum
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Severity of a match.
+type Severity string
+
+const (
+	SeverityError      Severity = "ERROR"
+	SeverityWarning    Severity = "WARNING"
+	SeverityInfo       Severity = "INFO"
+	SeverityExperiment Severity = "EXPERIMENT"
+	SeverityInventory  Severity = "INVENTORY"
+	// Used by newer versions of Semgrep.
+	SeverityCritical Severity = "CRITICAL"
+	SeverityHigh     Severity = "HIGH"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityLow      Severity = "LOW"
+	// Returned by OrUnknown for severities that are not in this package.
+	SeverityUnknown Severity = ""
+)
+
+var severities = []Severity{
+	SeverityError, SeverityWarning, SeverityInfo, SeverityExperiment,
+	SeverityInventory, SeverityCritical, SeverityHigh, SeverityMedium,
+	SeverityLow,
+}
+
+// ParseSeverity returns the severity in v.
+func ParseSeverity(v interface{}) Severity {
+	return Severity(tagOf(v))
+}
+
+// Known returns true if the severity is one of the constants.
+func (s Severity) Known() bool {
+	return contains(severities, s)
+}
+
+// OrUnknown returns the severity if it's known and SeverityUnknown otherwise.
+func (s Severity) OrUnknown() Severity {
+	return orUnknown(s, s.Known(), SeverityUnknown)
+}
+
+// Raw returns the severity as it was in the output.
+func (s Severity) Raw() string {
+	return string(s)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	v, err := decode(data)
+	*s = ParseSeverity(v)
+	return err
+}
+
+// EngineKind is the Semgrep engine that found a match.
+type EngineKind string
+
+const (
+	EngineOSS EngineKind = "OSS"
+	EnginePro EngineKind = "PRO"
+	// Returned by OrUnknown for engine kinds that are not in this package.
+	EngineUnknown EngineKind = ""
+)
+
+// ParseEngineKind returns the engine kind in v.
+func ParseEngineKind(v interface{}) EngineKind {
+	return EngineKind(tagOf(v))
+}
+
+// Known returns true if the engine kind is one of the constants.
+func (e EngineKind) Known() bool {
+	return e == EngineOSS || e == EnginePro
+}
+
+// OrUnknown returns the engine kind if it's known and EngineUnknown otherwise.
+func (e EngineKind) OrUnknown() EngineKind {
+	return orUnknown(e, e.Known(), EngineUnknown)
+}
+
+// Raw returns the engine kind as it was in the output.
+func (e EngineKind) Raw() string {
+	return string(e)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *EngineKind) UnmarshalJSON(data []byte) error {
+	v, err := decode(data)
+	*e = ParseEngineKind(v)
+	return err
+}
+
+// ValidationState is the result of validating a secret.
+type ValidationState string
+
+const (
+	ConfirmedValid   ValidationState = "CONFIRMED_VALID"
+	ConfirmedInvalid ValidationState = "CONFIRMED_INVALID"
+	ValidationError  ValidationState = "VALIDATION_ERROR"
+	NoValidator      ValidationState = "NO_VALIDATOR"
+	// Returned by OrUnknown for validation states that are not in this
+	// package.
+	UnknownValidationState ValidationState = ""
+)
+
+var validationStates = []ValidationState{
+	ConfirmedValid, ConfirmedInvalid, ValidationError, NoValidator,
+}
+
+// ParseValidationState returns the validation state in v.
+func ParseValidationState(v interface{}) ValidationState {
+	return ValidationState(tagOf(v))
+}
+
+// Known returns true if the validation state is one of the constants.
+func (s ValidationState) Known() bool {
+	return contains(validationStates, s)
+}
+
+// OrUnknown returns the validation state if it's known and
+// UnknownValidationState otherwise.
+func (s ValidationState) OrUnknown() ValidationState {
+	return orUnknown(s, s.Known(), UnknownValidationState)
+}
+
+// Raw returns the validation state as it was in the output.
+func (s ValidationState) Raw() string {
+	return string(s)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *ValidationState) UnmarshalJSON(data []byte) error {
+	v, err := decode(data)
+	*s = ParseValidationState(v)
+	return err
+}
+
+// ErrorLevel is the level of a Semgrep error.
+type ErrorLevel string
+
+const (
+	LevelError ErrorLevel = "error"
+	LevelWarn  ErrorLevel = "warn"
+	LevelInfo  ErrorLevel = "info"
+	// Returned by OrUnknown for error levels that are not in this package.
+	LevelUnknown ErrorLevel = ""
+)
+
+// ParseErrorLevel returns the error level in v.
+func ParseErrorLevel(v interface{}) ErrorLevel {
+	return ErrorLevel(tagOf(v))
+}
+
+// Known returns true if the error level is one of the constants.
+func (l ErrorLevel) Known() bool {
+	return l == LevelError || l == LevelWarn || l == LevelInfo
+}
+
+// OrUnknown returns the error level if it's known and LevelUnknown otherwise.
+func (l ErrorLevel) OrUnknown() ErrorLevel {
+	return orUnknown(l, l.Known(), LevelUnknown)
+}
+
+// Raw returns the error level as it was in the output.
+func (l ErrorLevel) Raw() string {
+	return string(l)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *ErrorLevel) UnmarshalJSON(data []byte) error {
+	v, err := decode(data)
+	*l = ParseErrorLevel(v)
+	return err
+}
+
+// ErrorKind is the kind of a Semgrep error.
+type ErrorKind string
+
+const (
+	LexicalError               ErrorKind = "Lexical error"
+	SyntaxError                ErrorKind = "Syntax error"
+	OtherSyntaxError           ErrorKind = "Other syntax error"
+	ASTBuilderError            ErrorKind = "AST builder error"
+	RuleParseError             ErrorKind = "Rule parse error"
+	SemgrepWarning             ErrorKind = "SemgrepWarning"
+	SemgrepError               ErrorKind = "SemgrepError"
+	InvalidRuleSchemaError     ErrorKind = "InvalidRuleSchemaError"
+	UnknownLanguageError       ErrorKind = "UnknownLanguageError"
+	InvalidYAML                ErrorKind = "Invalid YAML"
+	MatchingError              ErrorKind = "Internal matching error"
+	SemgrepMatchFound          ErrorKind = "Semgrep match found"
+	TooManyMatches             ErrorKind = "Too many matches"
+	FatalError                 ErrorKind = "Fatal error"
+	Timeout                    ErrorKind = "Timeout"
+	OutOfMemory                ErrorKind = "Out of memory"
+	TimeoutDuringInterfile     ErrorKind = "Timeout during interfile analysis"
+	OutOfMemoryDuringInterfile ErrorKind = "OOM during interfile analysis"
+	MissingPlugin              ErrorKind = "Missing plugin"
+	// The payload is the list of patterns as []interface{}.
+	PatternParseError ErrorKind = "PatternParseError"
+	// The payload is the list of locations as []interface{}.
+	PartialParsing ErrorKind = "PartialParsing"
+	// The payload is the incompatible rule as map[string]interface{}.
+	IncompatibleRule ErrorKind = "IncompatibleRule"
+	// Returned by OrUnknown for error kinds that are not in this package.
+	UnknownErrorKind ErrorKind = ""
+)
+
+var errorKinds = []ErrorKind{
+	LexicalError, SyntaxError, OtherSyntaxError, ASTBuilderError,
+	RuleParseError, SemgrepWarning, SemgrepError, InvalidRuleSchemaError,
+	UnknownLanguageError, InvalidYAML, MatchingError, SemgrepMatchFound,
+	TooManyMatches, FatalError, Timeout, OutOfMemory, TimeoutDuringInterfile,
+	OutOfMemoryDuringInterfile, MissingPlugin, PatternParseError,
+	PartialParsing, IncompatibleRule,
+}
+
+// Other names for the same kinds. Semgrep has variants of these without a
+// payload.
+var errorKindAliases = map[string]ErrorKind{
+	"Pattern parse error": PatternParseError,
+	"Incompatible rule":   IncompatibleRule,
+	"IncompatibleRule_":   IncompatibleRule,
+}
+
+// Known returns true if the error kind is one of the constants.
+func (k ErrorKind) Known() bool {
+	return contains(errorKinds, k)
+}
+
+// OrUnknown returns the error kind if it's known and UnknownErrorKind
+// otherwise.
+func (k ErrorKind) OrUnknown() ErrorKind {
+	return orUnknown(k, k.Known(), UnknownErrorKind)
+}
+
+// Raw returns the error kind as it was in the output. Aliases are already
+// replaced by their constant.
+func (k ErrorKind) Raw() string {
+	return string(k)
+}
+
+// ErrorType is the type of a Semgrep error. Some kinds have a payload.
+type ErrorType struct {
+	Kind    ErrorKind
+	Payload interface{}
+}
+
+// ParseErrorType returns the error type in v.
+func ParseErrorType(v interface{}) ErrorType {
+	tag, payload := variant(v)
+	kind, ok := errorKindAliases[tag]
+	if !ok {
+		kind = ErrorKind(tag)
+	}
+	return ErrorType{Kind: kind, Payload: payload}
+}
+
+// Known returns true if the error kind is one of the constants.
+func (t ErrorType) Known() bool {
+	return t.Kind.Known()
+}
+
+// String returns the kind.
+func (t ErrorType) String() string {
+	return string(t.Kind)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *ErrorType) UnmarshalJSON(data []byte) error {
+	v, err := decode(data)
+	*t = ParseErrorType(v)
+	return err
+}
+
+// MarshalJSON implements json.Marshaler. The error type is a string if it
+// doesn't have a payload and a tagged array if it does.
+func (t ErrorType) MarshalJSON() ([]byte, error) {
+	if t.Payload == nil {
+		return json.Marshal(string(t.Kind))
+	}
+	return json.Marshal([]interface{}{string(t.Kind), t.Payload})
+}
+
+// SkipReason is why Semgrep skipped a target.
+type SkipReason string
+
+const (
+	AlwaysSkipped                       SkipReason = "always_skipped"
+	SemgrepignorePatternsMatch          SkipReason = "semgrepignore_patterns_match"
+	CLIIncludeFlagsDoNotMatch           SkipReason = "cli_include_flags_do_not_match"
+	CLIExcludeFlagsMatch                SkipReason = "cli_exclude_flags_match"
+	ExceededSizeLimit                   SkipReason = "exceeded_size_limit"
+	AnalysisFailedParserOrInternalError SkipReason = "analysis_failed_parser_or_internal_error"
+	ExcludedByConfig                    SkipReason = "excluded_by_config"
+	WrongLanguage                       SkipReason = "wrong_language"
+	TooBig                              SkipReason = "too_big"
+	Minified                            SkipReason = "minified"
+	Binary                              SkipReason = "binary"
+	IrrelevantRule                      SkipReason = "irrelevant_rule"
+	TooManyMatchesSkip                  SkipReason = "too_many_matches"
+	GitignorePatternsMatch              SkipReason = "Gitignore_patterns_match"
+	Dotfile                             SkipReason = "Dotfile"
+	NonexistentFile                     SkipReason = "Nonexistent_file"
+	InsufficientPermissions             SkipReason = "insufficient_permissions"
+	// Returned by OrUnknown for skip reasons that are not in this package.
+	UnknownSkipReason SkipReason = ""
+)
+
+var skipReasons = []SkipReason{
+	AlwaysSkipped, SemgrepignorePatternsMatch, CLIIncludeFlagsDoNotMatch,
+	CLIExcludeFlagsMatch, ExceededSizeLimit, AnalysisFailedParserOrInternalError,
+	ExcludedByConfig, WrongLanguage, TooBig, Minified, Binary, IrrelevantRule,
+	TooManyMatchesSkip, GitignorePatternsMatch, Dotfile, NonexistentFile,
+	InsufficientPermissions,
+}
+
+// ParseSkipReason returns the skip reason in v.
+func ParseSkipReason(v interface{}) SkipReason {
+	return SkipReason(tagOf(v))
+}
+
+// Known returns true if the skip reason is one of the constants.
+func (r SkipReason) Known() bool {
+	return contains(skipReasons, r)
+}
+
+// OrUnknown returns the skip reason if it's known and UnknownSkipReason
+// otherwise.
+func (r SkipReason) OrUnknown() SkipReason {
+	return orUnknown(r, r.Known(), UnknownSkipReason)
+}
+
+// Raw returns the skip reason as it was in the output.
+func (r SkipReason) Raw() string {
+	return string(r)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *SkipReason) UnmarshalJSON(data []byte) error {
+	v, err := decode(data)
+	*r = ParseSkipReason(v)
+	return err
+}
+
+// ----------
+
+// variant returns the tag and payload of an ATD variant. If v is not a
+// variant, the tag is v in JSON so the raw value is not lost.
+func variant(v interface{}) (tag string, payload interface{}) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case []interface{}:
+		// ["Tag"] or ["Tag", payload].
+		if len(val) == 1 || len(val) == 2 {
+			if s, ok := val[0].(string); ok {
+				if len(val) == 2 {
+					payload = val[1]
+				}
+				return s, payload
+			}
+		}
+	case map[string]interface{}:
+		// {"Tag": payload}.
+		if len(val) == 1 {
+			for k, p := range val {
+				return k, p
+			}
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v), nil
+	}
+	return string(data), nil
+}
+
+// tagOf returns the tag of a variant.
+func tagOf(v interface{}) string {
+	tag, _ := variant(v)
+	return tag
+}
+
+// decode decodes the JSON in data to interface{}.
+func decode(data []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// orUnknown returns v if it's known and unknown otherwise.
+func orUnknown[T any](v T, known bool, unknown T) T {
+	if known {
+		return v
+	}
+	return unknown
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
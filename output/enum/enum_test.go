@@ -0,0 +1,163 @@
+package enum
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseErrorType(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		want      ErrorType
+		wantKnown bool
+	}{
+		{
+			name:      "string",
+			json:      `"Syntax error"`,
+			want:      ErrorType{Kind: SyntaxError},
+			wantKnown: true,
+		},
+		{
+			name:      "tagged array",
+			json:      `["PatternParseError", ["foo(", "bar("]]`,
+			want:      ErrorType{Kind: PatternParseError, Payload: []interface{}{"foo(", "bar("}},
+			wantKnown: true,
+		},
+		{
+			name:      "alias without payload",
+			json:      `"Pattern parse error"`,
+			want:      ErrorType{Kind: PatternParseError},
+			wantKnown: true,
+		},
+		{
+			name: "tagged array with object",
+			json: `["IncompatibleRule_", {"rule_id": "a", "this_version": "1.0.0"}]`,
+			want: ErrorType{Kind: IncompatibleRule, Payload: map[string]interface{}{
+				"rule_id": "a", "this_version": "1.0.0",
+			}},
+			wantKnown: true,
+		},
+		{
+			name:      "object",
+			json:      `{"PartialParsing": []}`,
+			want:      ErrorType{Kind: PartialParsing, Payload: []interface{}{}},
+			wantKnown: true,
+		},
+		{
+			name: "unknown string",
+			json: `"New error"`,
+			want: ErrorType{Kind: "New error"},
+		},
+		{
+			name: "unknown shape",
+			json: `[1, 2, 3]`,
+			want: ErrorType{Kind: "[1,2,3]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ErrorType
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalJSON() = %#v, want %#v", got, tt.want)
+			}
+			if got.Known() != tt.wantKnown {
+				t.Errorf("Known() = %v, want %v", got.Known(), tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestErrorType_MarshalJSON(t *testing.T) {
+	for _, tt := range []struct {
+		in   ErrorType
+		want string
+	}{
+		{ErrorType{Kind: Timeout}, `"Timeout"`},
+		{ErrorType{Kind: PatternParseError, Payload: []string{"foo("}}, `["PatternParseError",["foo("]]`},
+	} {
+		got, err := json.Marshal(tt.in)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("MarshalJSON() = %s, %v, want %s", got, err, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	// Each parse function with a known string, a tagged array, and an unknown
+	// value.
+	var got struct {
+		Severity   []Severity        `json:"severity"`
+		Engine     []EngineKind      `json:"engine"`
+		Validation []ValidationState `json:"validation"`
+		Level      []ErrorLevel      `json:"level"`
+		Reason     []SkipReason      `json:"reason"`
+	}
+	data := `{
+		"severity": ["ERROR", ["WARNING"], "SEVERE"],
+		"engine": ["OSS", ["PRO"], "ULTRA"],
+		"validation": ["NO_VALIDATOR", ["CONFIRMED_VALID"], "MAYBE"],
+		"level": ["warn", ["error"], "fatal"],
+		"reason": ["minified", ["Dotfile"], "too_old"]
+	}`
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, got []string, known []bool, want []string) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+		if !reflect.DeepEqual(known, []bool{true, true, false}) {
+			t.Errorf("%s Known() = %v, want [true true false]", name, known)
+		}
+	}
+	s := got.Severity
+	check("Severity", []string{string(s[0]), string(s[1]), string(s[2])},
+		[]bool{s[0].Known(), s[1].Known(), s[2].Known()}, []string{"ERROR", "WARNING", "SEVERE"})
+	e := got.Engine
+	check("EngineKind", []string{string(e[0]), string(e[1]), string(e[2])},
+		[]bool{e[0].Known(), e[1].Known(), e[2].Known()}, []string{"OSS", "PRO", "ULTRA"})
+	v := got.Validation
+	check("ValidationState", []string{string(v[0]), string(v[1]), string(v[2])},
+		[]bool{v[0].Known(), v[1].Known(), v[2].Known()}, []string{"NO_VALIDATOR", "CONFIRMED_VALID", "MAYBE"})
+	l := got.Level
+	check("ErrorLevel", []string{string(l[0]), string(l[1]), string(l[2])},
+		[]bool{l[0].Known(), l[1].Known(), l[2].Known()}, []string{"warn", "error", "fatal"})
+	r := got.Reason
+	check("SkipReason", []string{string(r[0]), string(r[1]), string(r[2])},
+		[]bool{r[0].Known(), r[1].Known(), r[2].Known()}, []string{"minified", "Dotfile", "too_old"})
+
+	if ParseSeverity(nil) != "" {
+		t.Errorf("ParseSeverity(nil) = %q, want empty", ParseSeverity(nil))
+	}
+}
+
+func TestOrUnknown(t *testing.T) {
+	if got := SeverityError.OrUnknown(); got != SeverityError {
+		t.Errorf("OrUnknown() = %q, want %q", got, SeverityError)
+	}
+	for _, s := range []Severity{"SEVERE", ParseSeverity(nil)} {
+		if s.OrUnknown() != SeverityUnknown {
+			t.Errorf("%q.OrUnknown() = %q, want SeverityUnknown", s, s.OrUnknown())
+		}
+	}
+	if got := Severity("SEVERE").Raw(); got != "SEVERE" {
+		t.Errorf("Raw() = %q, want %q", got, "SEVERE")
+	}
+	if EngineKind("ULTRA").OrUnknown() != EngineUnknown ||
+		ValidationState("MAYBE").OrUnknown() != UnknownValidationState ||
+		ErrorLevel("fatal").OrUnknown() != LevelUnknown ||
+		ParseErrorType("New error").Kind.OrUnknown() != UnknownErrorKind ||
+		SkipReason("too_old").OrUnknown() != UnknownSkipReason {
+		t.Errorf("OrUnknown() didn't return the Unknown constant")
+	}
+	if got := ParseErrorType("New error").Kind.Raw(); got != "New error" {
+		t.Errorf("Raw() = %q, want %q", got, "New error")
+	}
+}
@@ -0,0 +1,38 @@
+package output
+
+import "github.com/parsiya/semgrep_go/output/enum"
+
+// Typed accessors for the enum fields that are interface{} in the generated
+// structs. Values that are not known are returned as-is, check them with the
+// Known method.
+
+// Severity returns the severity of the match.
+func (c CliMatch) Severity() enum.Severity {
+	return enum.ParseSeverity(c.Extra.Severity)
+}
+
+// EngineKind returns the engine that found the match.
+func (c CliMatch) EngineKind() enum.EngineKind {
+	return enum.ParseEngineKind(c.Extra.EngineKind)
+}
+
+// ValidationState returns the validation state of the match. It's empty if
+// the match doesn't have one.
+func (c CliMatch) ValidationState() enum.ValidationState {
+	return enum.ParseValidationState(c.Extra.ValidationState)
+}
+
+// ErrorLevel returns the level of the error.
+func (e CliError) ErrorLevel() enum.ErrorLevel {
+	return enum.ParseErrorLevel(e.Level)
+}
+
+// ErrorType returns the type of the error and its payload.
+func (e CliError) ErrorType() enum.ErrorType {
+	return enum.ParseErrorType(e.Type)
+}
+
+// SkipReason returns why the target was skipped.
+func (s SkippedTarget) SkipReason() enum.SkipReason {
+	return enum.ParseSkipReason(s.Reason)
+}
@@ -0,0 +1,52 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/output/enum"
+)
+
+func TestCliMatch_Enums(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	severities := map[enum.Severity]int{}
+	for _, r := range out.Results {
+		severities[r.Severity()]++
+		if r.EngineKind() != enum.EngineOSS {
+			t.Errorf("EngineKind() = %q, want %q", r.EngineKind(), enum.EngineOSS)
+		}
+		if r.ValidationState() != enum.NoValidator {
+			t.Errorf("ValidationState() = %q, want %q", r.ValidationState(), enum.NoValidator)
+		}
+	}
+	want := map[enum.Severity]int{enum.SeverityWarning: 52, enum.SeverityError: 12, enum.SeverityInfo: 3}
+	if !reflect.DeepEqual(severities, want) {
+		t.Errorf("Severity() counts = %v, want %v", severities, want)
+	}
+
+	if len(out.Errors) != 29 {
+		t.Fatalf("got %d errors, want 29", len(out.Errors))
+	}
+	for _, e := range out.Errors {
+		if e.ErrorLevel() != enum.LevelWarn || e.ErrorType().Kind != enum.SyntaxError {
+			t.Errorf("ErrorLevel() = %q, ErrorType() = %v", e.ErrorLevel(), e.ErrorType())
+		}
+	}
+}
+
+func TestCliError_ErrorType_Payload(t *testing.T) {
+	e := CliError{Type: []interface{}{"PartialParsing", []interface{}{"loc"}}}
+	got := e.ErrorType()
+	if got.Kind != enum.PartialParsing || !reflect.DeepEqual(got.Payload, []interface{}{"loc"}) {
+		t.Errorf("ErrorType() = %#v", got)
+	}
+
+	s := SkippedTarget{Reason: "exceeded_size_limit"}
+	if s.SkipReason() != enum.ExceededSizeLimit {
+		t.Errorf("SkipReason() = %q, want %q", s.SkipReason(), enum.ExceededSizeLimit)
+	}
+}
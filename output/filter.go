@@ -0,0 +1,334 @@
+package output
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/parsiya/semgrep_go/output/enum"
+)
+
+// Predicate selects results in Output.Filter. Path and Rule are optional, if
+// they are set, Filter also uses them to remove the paths, errors, and skipped
+// rules that don't belong to the selected results.
+type Predicate struct {
+	// Match returns true if the result should be kept. If it's nil, the
+	// result's path and rule ID are checked with Path and Rule instead. A
+	// predicate without any functions keeps everything.
+	Match func(CliMatch) bool
+	// Path returns true if a file path should be kept.
+	Path func(string) bool
+	// Rule returns true if a rule ID should be kept.
+	Rule func(string) bool
+}
+
+// matches returns true if the predicate keeps the result.
+func (p Predicate) matches(c CliMatch) bool {
+	if p.Match != nil {
+		return p.Match(c)
+	}
+	if p.Path != nil && !p.Path(c.FilePath()) {
+		return false
+	}
+	return p.Rule == nil || p.Rule(c.RuleID())
+}
+
+// Where returns a predicate from a function.
+func Where(match func(CliMatch) bool) Predicate {
+	return Predicate{Match: match}
+}
+
+// BySeverity keeps results with any of the severities.
+func BySeverity(severities ...enum.Severity) Predicate {
+	return Where(func(c CliMatch) bool {
+		sev := c.Severity()
+		for _, s := range severities {
+			if s == sev {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// ByRuleGlob keeps results where the rule ID matches any of the globs in
+// path.Match syntax. E.g., `javascript.*`.
+func ByRuleGlob(globs ...string) Predicate {
+	rule := func(id string) bool {
+		for _, g := range globs {
+			if ok, _ := path.Match(g, id); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return Predicate{
+		Match: func(c CliMatch) bool { return rule(c.RuleID()) },
+		Rule:  rule,
+	}
+}
+
+// ByPathPrefix keeps results in any of the files or directories. `src` matches
+// `src/app.js` but not `srcs/app.js`.
+func ByPathPrefix(prefixes ...string) Predicate {
+	inPath := func(p string) bool {
+		p = filepath.ToSlash(p)
+		for _, prefix := range prefixes {
+			prefix = strings.TrimSuffix(filepath.ToSlash(prefix), "/")
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	return Predicate{
+		Match: func(c CliMatch) bool { return inPath(c.FilePath()) },
+		Path:  inPath,
+	}
+}
+
+// ByMetadata keeps results where the metadata field has any of the values. If
+// the field is a list, any item can match. If no values are passed, results
+// with the field are kept.
+func ByMetadata(name string, values ...string) Predicate {
+	return Where(func(c CliMatch) bool {
+		items := metadataValues(c, name)
+		if len(values) == 0 {
+			return items != nil
+		}
+		for _, item := range items {
+			for _, v := range values {
+				if item == v {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// ByLineRange keeps results that overlap the lines from start to end, both
+// inclusive.
+func ByLineRange(start, end int) Predicate {
+	return Where(func(c CliMatch) bool {
+		return c.Start.Line <= end && c.End.Line >= start
+	})
+}
+
+// Not keeps results that don't match the predicate. Paths and rules are
+// inverted too, e.g., `Not(ByPathPrefix("vendor"))` removes `vendor` from the
+// scanned paths.
+func Not(p Predicate) Predicate {
+	return Predicate{
+		Match: func(c CliMatch) bool { return !p.matches(c) },
+		Path:  not(p.Path),
+		Rule:  not(p.Rule),
+	}
+}
+
+// Any keeps results that match any of the predicates. Paths and rules are only
+// filtered if all the predicates filter them.
+func Any(preds ...Predicate) Predicate {
+	p := Predicate{
+		Match: func(c CliMatch) bool {
+			for _, pred := range preds {
+				if pred.matches(c) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+	paths := make([]func(string) bool, len(preds))
+	rules := make([]func(string) bool, len(preds))
+	for i, pred := range preds {
+		paths[i], rules[i] = pred.Path, pred.Rule
+	}
+	p.Path, p.Rule = or(paths), or(rules)
+	return p
+}
+
+func not(f func(string) bool) func(string) bool {
+	if f == nil {
+		return nil
+	}
+	return func(s string) bool { return !f(s) }
+}
+
+// or returns nil if any of the functions is nil.
+func or(fs []func(string) bool) func(string) bool {
+	if len(fs) == 0 {
+		return nil
+	}
+	for _, f := range fs {
+		if f == nil {
+			return nil
+		}
+	}
+	return func(s string) bool {
+		for _, f := range fs {
+			if f(s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Filter returns a copy of the output with the results that match all the
+// predicates. Scanned and skipped paths, errors, skipped rules, and
+// rules_by_engine are filtered by the path and rule scopes of the predicates.
+// Other fields are copied as-is.
+func (o Output) Filter(preds ...Predicate) Output {
+	keepPath := func(p string) bool {
+		for _, pred := range preds {
+			if pred.Path != nil && !pred.Path(p) {
+				return false
+			}
+		}
+		return true
+	}
+	keepRule := func(id string) bool {
+		for _, pred := range preds {
+			if pred.Rule != nil && !pred.Rule(id) {
+				return false
+			}
+		}
+		return true
+	}
+
+	out := o
+	out.Results = make([]CliMatch, 0, len(o.Results))
+	for _, r := range o.Results {
+		keep := true
+		for _, pred := range preds {
+			if !pred.matches(r) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out.Results = append(out.Results, r)
+		}
+	}
+
+	out.Errors = make([]CliError, 0, len(o.Errors))
+	for _, e := range o.Errors {
+		if e.Path != nil && !keepPath(string(*e.Path)) {
+			continue
+		}
+		if e.RuleId != nil && !keepRule(string(*e.RuleId)) {
+			continue
+		}
+		out.Errors = append(out.Errors, e)
+	}
+
+	if o.Paths.Scanned != nil {
+		out.Paths.Scanned = make([]Fpath, 0, len(o.Paths.Scanned))
+		for _, p := range o.Paths.Scanned {
+			if keepPath(string(p)) {
+				out.Paths.Scanned = append(out.Paths.Scanned, p)
+			}
+		}
+	}
+	if o.Paths.Skipped != nil {
+		out.Paths.Skipped = make([]SkippedTarget, 0, len(o.Paths.Skipped))
+		for _, s := range o.Paths.Skipped {
+			if keepPath(string(s.Path)) && (s.RuleId == nil || keepRule(string(*s.RuleId))) {
+				out.Paths.Skipped = append(out.Paths.Skipped, s)
+			}
+		}
+	}
+
+	if o.SkippedRules != nil {
+		out.SkippedRules = make([]SkippedRule, 0, len(o.SkippedRules))
+		for _, s := range o.SkippedRules {
+			if keepRule(string(s.RuleId)) {
+				out.SkippedRules = append(out.SkippedRules, s)
+			}
+		}
+	}
+	if o.RulesByEngine != nil {
+		out.RulesByEngine = make([]RuleIdAndEngineKind, 0, len(o.RulesByEngine))
+		for _, r := range o.RulesByEngine {
+			// [rule_id, engine_kind].
+			if len(r) > 0 {
+				if id, ok := r[0].(string); ok && !keepRule(id) {
+					continue
+				}
+			}
+			out.RulesByEngine = append(out.RulesByEngine, r)
+		}
+	}
+	return out
+}
+
+// ----------
+
+// Key returns the group keys of a result in GroupBy. A result can be in more
+// than one group, e.g., a rule with multiple CWEs.
+type Key func(CliMatch) []string
+
+// RuleKey groups results by rule ID.
+func RuleKey(c CliMatch) []string {
+	return []string{c.RuleID()}
+}
+
+// FileKey groups results by file path.
+func FileKey(c CliMatch) []string {
+	return []string{c.FilePath()}
+}
+
+// DirKey groups results by the directory of the file.
+func DirKey(c CliMatch) []string {
+	return []string{path.Dir(filepath.ToSlash(c.FilePath()))}
+}
+
+// SeverityKey groups results by severity.
+func SeverityKey(c CliMatch) []string {
+	return []string{string(c.Severity())}
+}
+
+// MetadataKey groups results by a metadata field. If the field is a list,
+// the result is in a group for each item. Results without the field are in
+// the "" group.
+func MetadataKey(name string) Key {
+	return func(c CliMatch) []string {
+		values := metadataValues(c, name)
+		if len(values) == 0 {
+			return []string{""}
+		}
+		return values
+	}
+}
+
+// GroupBy returns the results grouped by the key. Results keep their order in
+// each group.
+func (o Output) GroupBy(key Key) map[string][]CliMatch {
+	groups := make(map[string][]CliMatch)
+	for _, r := range o.Results {
+		for _, k := range key(r) {
+			groups[k] = append(groups[k], r)
+		}
+	}
+	return groups
+}
+
+// metadataValues returns the metadata field as strings. It returns nil if the
+// field doesn't exist.
+func metadataValues(c CliMatch, name string) []string {
+	v, err := c.Metadata(name)
+	if err != nil {
+		return nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return []string{fmt.Sprint(v)}
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		values = append(values, fmt.Sprint(item))
+	}
+	return values
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/output/enum"
+)
+
+func TestOutput_Filter(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		preds       []Predicate
+		wantResults int
+		wantScanned int
+		wantErrors  int
+	}{
+		{
+			name:        "no predicates",
+			wantResults: 67,
+			wantScanned: 977,
+			wantErrors:  29,
+		},
+		{
+			name:        "rule glob",
+			preds:       []Predicate{ByRuleGlob("javascript.*")},
+			wantResults: 53,
+			wantScanned: 977,
+			wantErrors:  29,
+		},
+		{
+			name:        "path prefix and severity",
+			preds:       []Predicate{ByPathPrefix("juice-shop/routes/"), BySeverity(enum.SeverityError)},
+			wantResults: 2,
+			wantScanned: 61,
+			wantErrors:  0,
+		},
+		{
+			name:        "metadata",
+			preds:       []Predicate{ByRuleGlob("javascript.*"), ByMetadata("confidence", "HIGH")},
+			wantResults: 8,
+			wantScanned: 977,
+			wantErrors:  29,
+		},
+		{
+			name:        "metadata list",
+			preds:       []Predicate{ByMetadata("cwe", "CWE-89: Improper Neutralization of Special Elements used in an SQL Command ('SQL Injection')")},
+			wantResults: 6,
+			wantScanned: 977,
+			wantErrors:  29,
+		},
+		{
+			name:        "not path prefix",
+			preds:       []Predicate{Not(ByPathPrefix("juice-shop/routes"))},
+			wantResults: 38,
+			wantScanned: 916,
+			wantErrors:  29,
+		},
+		{
+			name:        "any",
+			preds:       []Predicate{Any(BySeverity(enum.SeverityInfo), ByRuleGlob("yaml.*"))},
+			wantResults: 8,
+			wantScanned: 977,
+			wantErrors:  29,
+		},
+		{
+			name: "line range",
+			preds: []Predicate{
+				ByPathPrefix("juice-shop/routes/login.ts"),
+				ByLineRange(30, 36),
+			},
+			wantResults: 1,
+			wantScanned: 1,
+		},
+		{
+			name:        "empty predicate",
+			preds:       []Predicate{{}, Not(Predicate{Rule: func(string) bool { return false }})},
+			wantResults: 67,
+			wantScanned: 977,
+			wantErrors:  29,
+		},
+		{
+			name: "path without match",
+			preds: []Predicate{{Path: func(p string) bool {
+				return strings.HasPrefix(p, "juice-shop/routes/")
+			}}},
+			wantResults: 29,
+			wantScanned: 61,
+		},
+		{
+			name: "where",
+			preds: []Predicate{Where(func(c CliMatch) bool {
+				return c.RuleID() == "does-not-exist"
+			})},
+			wantScanned: 977,
+			wantErrors:  29,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := out.Filter(tt.preds...)
+			if len(got.Results) != tt.wantResults {
+				t.Errorf("Filter() got %d results, want %d", len(got.Results), tt.wantResults)
+			}
+			if len(got.Paths.Scanned) != tt.wantScanned {
+				t.Errorf("Filter() got %d scanned paths, want %d", len(got.Paths.Scanned), tt.wantScanned)
+			}
+			if len(got.Errors) != tt.wantErrors {
+				t.Errorf("Filter() got %d errors, want %d", len(got.Errors), tt.wantErrors)
+			}
+		})
+	}
+
+	// The original output must not change.
+	if len(out.Results) != 67 || len(out.Paths.Scanned) != 977 {
+		t.Errorf("Filter() modified the original output")
+	}
+}
+
+func TestOutput_GroupBy(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		key        Key
+		wantGroups int
+		group      string
+		wantCount  int
+	}{
+		{"rule", RuleKey, 28, "javascript.sequelize.security.audit.sequelize-injection-express.express-sequelize-injection", 6},
+		{"file", FileKey, 37, "juice-shop/routes/login.ts", 1},
+		{"dir", DirKey, 0, "juice-shop/routes", 29},
+		{"severity", SeverityKey, 3, "WARNING", 52},
+		{"metadata", MetadataKey("confidence"), 3, "HIGH", 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := out.GroupBy(tt.key)
+			if tt.wantGroups != 0 && len(got) != tt.wantGroups {
+				t.Errorf("GroupBy() got %d groups, want %d", len(got), tt.wantGroups)
+			}
+			if len(got[tt.group]) != tt.wantCount {
+				t.Errorf("GroupBy() group %q has %d results, want %d", tt.group, len(got[tt.group]), tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package output
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/parsiya/semgrep_go/output/enum"
+	"github.com/parsiya/semgrep_go/output/gitlab"
+)
+
+// ToGitLabSAST converts the output to a GitLab SAST report
+// (`gl-sast-report.json`). See toGitLab for the details.
+func (o Output) ToGitLabSAST() gitlab.Report {
+	return o.toGitLab(gitlab.TypeSAST, gitlab.SASTSchema)
+}
+
+// ToGitLabSecrets converts the output to a GitLab Secret Detection report
+// (`gl-secret-detection-report.json`). Semgrep doesn't know the commit, so
+// the location has the `0000000` commit like `semgrep --gitlab-secrets`. The
+// matched code in Extra.Lines is the raw source code extract.
+func (o Output) ToGitLabSecrets() gitlab.Report {
+	return o.toGitLab(gitlab.TypeSecretDetection, gitlab.SecretDetectionSchema)
+}
+
+// toGitLab converts the output to a GitLab report:
+//   - The id of each vulnerability is a UUID created from the fingerprint. If
+//     Semgrep didn't return one, the rule ID, path, and matched code are used
+//     instead. The id doesn't change between scans if the code doesn't.
+//     Identical findings get unique ids.
+//   - The identifiers are the rule ID and the cwe and owasp metadata.
+//   - Ignored results are flagged as false positives.
+//   - Errors are scan messages.
+//   - Start and end times are the current time. Change them if you know when
+//     the scan ran.
+func (o Output) toGitLab(typ, schema string) gitlab.Report {
+	tool := gitlab.Tool{
+		ID:     "semgrep",
+		Name:   "Semgrep",
+		URL:    "https://semgrep.dev",
+		Vendor: gitlab.Vendor{Name: "Semgrep"},
+	}
+	if o.Version != nil {
+		tool.Version = string(*o.Version)
+	}
+	now := time.Now().UTC().Format(gitlab.TimeFormat)
+	report := gitlab.Report{
+		Schema:  schema,
+		Version: gitlab.SchemaVersion,
+		Scan: gitlab.Scan{
+			Analyzer:  tool,
+			Scanner:   tool,
+			Type:      typ,
+			StartTime: now,
+			EndTime:   now,
+			Status:    gitlab.StatusSuccess,
+		},
+		Vulnerabilities: make([]gitlab.Vulnerability, 0, len(o.Results)),
+	}
+
+	for _, e := range o.Errors {
+		msg := gitlab.Message{Level: gitlab.LevelInfo, Value: e.ErrorType().String()}
+		switch e.ErrorLevel() {
+		case enum.LevelError:
+			msg.Level = gitlab.LevelFatal
+		case enum.LevelWarn:
+			msg.Level = gitlab.LevelWarn
+		}
+		if e.Message != nil && *e.Message != "" {
+			msg.Value = *e.Message
+		}
+		report.Scan.Messages = append(report.Scan.Messages, msg)
+	}
+
+	ids := set{}
+	for _, r := range o.Results {
+		v := gitLabVulnerability(r, typ)
+		v.ID = uniqueID(ids, r, uuid5)
+		report.Vulnerabilities = append(report.Vulnerabilities, v)
+	}
+	return report
+}
+
+// gitLabVulnerability converts a result.
+func gitLabVulnerability(c CliMatch, typ string) gitlab.Vulnerability {
+	v := gitlab.Vulnerability{
+		Category:    typ,
+		Name:        c.RuleID(),
+		Message:     c.Message(),
+		Description: c.Message(),
+		Severity:    gitLabSeverity(c.Severity()),
+		Scanner:     &gitlab.ScannerRef{ID: "semgrep", Name: "Semgrep"},
+		Location: gitlab.Location{
+			File:      filepath.ToSlash(c.FilePath()),
+			StartLine: c.Start.Line,
+			EndLine:   c.End.Line,
+		},
+		Identifiers: gitLabIdentifiers(c),
+	}
+	if typ == gitlab.TypeSecretDetection {
+		v.Location.Commit = &gitlab.Commit{Sha: "0000000"}
+		if c.Extra.Lines != requiresLogin {
+			v.RawSourceCodeExtract = c.Extra.Lines
+		}
+	}
+	for _, ref := range metadataValues(c, "references") {
+		v.Links = append(v.Links, gitlab.Link{URL: ref})
+	}
+	if c.Extra.IsIgnored != nil && *c.Extra.IsIgnored {
+		v.Flags = []gitlab.Flag{{
+			Type:        "flagged-as-likely-false-positive",
+			Origin:      "Semgrep",
+			Description: "The finding is ignored with a nosemgrep comment.",
+		}}
+	}
+	return v
+}
+
+// gitLabSeverity converts a Semgrep severity to a GitLab severity.
+func gitLabSeverity(sev enum.Severity) string {
+	switch sev {
+	case enum.SeverityCritical:
+		return gitlab.SeverityCritical
+	case enum.SeverityError, enum.SeverityHigh:
+		return gitlab.SeverityHigh
+	case enum.SeverityWarning, enum.SeverityMedium:
+		return gitlab.SeverityMedium
+	case enum.SeverityInfo, enum.SeverityLow:
+		return gitlab.SeverityLow
+	case enum.SeverityExperiment, enum.SeverityInventory:
+		return gitlab.SeverityInfo
+	}
+	return gitlab.SeverityUnknown
+}
+
+// `CWE-327: Use of a Broken or Risky Cryptographic Algorithm`.
+var cweID = regexp.MustCompile(`^CWE-(\d+)`)
+
+// gitLabIdentifiers returns the rule ID and the CWE and OWASP identifiers.
+func gitLabIdentifiers(c CliMatch) []gitlab.Identifier {
+	url := "https://semgrep.dev/r/" + c.RuleID()
+	if source := metadataValues(c, "source"); len(source) > 0 {
+		url = source[0]
+	}
+	ids := []gitlab.Identifier{{
+		Type:  "semgrep_id",
+		Name:  c.RuleID(),
+		Value: c.RuleID(),
+		URL:   url,
+	}}
+	for _, cwe := range metadataValues(c, "cwe") {
+		m := cweID.FindStringSubmatch(cwe)
+		if m == nil {
+			continue
+		}
+		ids = append(ids, gitlab.Identifier{
+			Type:  "cwe",
+			Name:  cwe,
+			Value: m[1],
+			URL:   fmt.Sprintf("https://cwe.mitre.org/data/definitions/%s.html", m[1]),
+		})
+	}
+	for _, owasp := range metadataValues(c, "owasp") {
+		// `A02:2021 - Cryptographic Failures`.
+		value := strings.TrimSpace(strings.SplitN(owasp, " - ", 2)[0])
+		ids = append(ids, gitlab.Identifier{Type: "owasp", Name: owasp, Value: value})
+	}
+	return ids
+}
+
+// The URL namespace for UUIDs from RFC 4122.
+var uuidNamespace = [16]byte{
+	0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}
+
+// uuid5 returns a version 5 (SHA-1) UUID of the name.
+func uuid5(name string) string {
+	h := sha1.New()
+	h.Write(uuidNamespace[:])
+	h.Write([]byte(name))
+	sum := h.Sum(nil)[:16]
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
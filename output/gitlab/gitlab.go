@@ -0,0 +1,172 @@
+// Package gitlab has the types for GitLab's SAST (`gl-sast-report.json`) and
+// Secret Detection (`gl-secret-detection-report.json`) reports. See
+// output.Output.ToGitLabSAST and output.Output.ToGitLabSecrets.
+//
+// The schemas are at
+// https://gitlab.com/gitlab-org/security-products/security-report-schemas.
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SchemaVersion is the version of the report schemas.
+const SchemaVersion = "15.0.4"
+
+// Schemas of the reports.
+const (
+	SASTSchema            = "https://gitlab.com/gitlab-org/security-products/security-report-schemas/-/raw/v15.0.4/dist/sast-report-format.json"
+	SecretDetectionSchema = "https://gitlab.com/gitlab-org/security-products/security-report-schemas/-/raw/v15.0.4/dist/secret-detection-report-format.json"
+)
+
+// Report types. They are used in Scan.Type and Vulnerability.Category.
+const (
+	TypeSAST            = "sast"
+	TypeSecretDetection = "secret_detection"
+)
+
+// Vulnerability severities.
+const (
+	SeverityCritical = "Critical"
+	SeverityHigh     = "High"
+	SeverityMedium   = "Medium"
+	SeverityLow      = "Low"
+	SeverityInfo     = "Info"
+	SeverityUnknown  = "Unknown"
+)
+
+// Scan statuses.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Scan message levels.
+const (
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelFatal = "fatal"
+)
+
+// The time format of Scan.StartTime and Scan.EndTime.
+const TimeFormat = "2006-01-02T15:04:05"
+
+// Report is a SAST or Secret Detection report.
+type Report struct {
+	Schema          string          `json:"$schema,omitempty"`
+	Version         string          `json:"version"`
+	Scan            Scan            `json:"scan"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+// Scan is the metadata of the scan.
+type Scan struct {
+	Analyzer  Tool      `json:"analyzer"`
+	Scanner   Tool      `json:"scanner"`
+	Type      string    `json:"type"`
+	StartTime string    `json:"start_time"`
+	EndTime   string    `json:"end_time"`
+	Status    string    `json:"status"`
+	Messages  []Message `json:"messages,omitempty"`
+}
+
+// Tool is the analyzer or the scanner.
+type Tool struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	Version string `json:"version"`
+	Vendor  Vendor `json:"vendor"`
+}
+
+// Vendor of a tool.
+type Vendor struct {
+	Name string `json:"name"`
+}
+
+// Message is a message from the scan, e.g., an error.
+type Message struct {
+	Level string `json:"level"`
+	Value string `json:"value"`
+}
+
+// Vulnerability is a finding.
+type Vulnerability struct {
+	ID          string       `json:"id"`
+	Category    string       `json:"category,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	Description string       `json:"description,omitempty"`
+	CVE         string       `json:"cve,omitempty"`
+	Severity    string       `json:"severity,omitempty"`
+	Confidence  string       `json:"confidence,omitempty"`
+	Solution    string       `json:"solution,omitempty"`
+	Scanner     *ScannerRef  `json:"scanner,omitempty"`
+	Location    Location     `json:"location"`
+	Identifiers []Identifier `json:"identifiers"`
+	Links       []Link       `json:"links,omitempty"`
+	Flags       []Flag       `json:"flags,omitempty"`
+	// Only in Secret Detection reports.
+	RawSourceCodeExtract string `json:"raw_source_code_extract,omitempty"`
+}
+
+// ScannerRef is the scanner that found a vulnerability.
+type ScannerRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Location of a vulnerability. Commit is only in Secret Detection reports.
+type Location struct {
+	File      string  `json:"file,omitempty"`
+	StartLine int     `json:"start_line,omitempty"`
+	EndLine   int     `json:"end_line,omitempty"`
+	Class     string  `json:"class,omitempty"`
+	Method    string  `json:"method,omitempty"`
+	Commit    *Commit `json:"commit,omitempty"`
+}
+
+// Commit where a secret was found.
+type Commit struct {
+	Author  string `json:"author,omitempty"`
+	Date    string `json:"date,omitempty"`
+	Message string `json:"message,omitempty"`
+	Sha     string `json:"sha"`
+}
+
+// Identifier of a vulnerability, e.g., the rule or a CWE.
+type Identifier struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Link to more information about a vulnerability.
+type Link struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url"`
+}
+
+// Flag is a note about a vulnerability, e.g., that it's a false positive.
+type Flag struct {
+	Type        string `json:"type"`
+	Origin      string `json:"origin"`
+	Description string `json:"description"`
+}
+
+// Parse reads a report, e.g., from `semgrep --gitlab-sast` or
+// `semgrep --gitlab-secrets`.
+func Parse(r io.Reader) (Report, error) {
+	var report Report
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return report, fmt.Errorf("failed to deserialize the GitLab report: %w", err)
+	}
+	if report.Scan.Type == "" {
+		return report, errors.New("failed to deserialize the GitLab report: scan.type is missing")
+	}
+	return report, nil
+}
@@ -0,0 +1,58 @@
+package gitlab
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// Similar to `semgrep --gitlab-sast`.
+	data := `{
+		"$schema": "https://gitlab.com/gitlab-org/security-products/security-report-schemas/-/blob/v15.0.0/dist/sast-report-format.json",
+		"version": "15.0.4",
+		"scan": {
+			"start_time": "2023-12-01T10:00:00", "end_time": "2023-12-01T10:01:00",
+			"analyzer": {"id": "semgrep", "name": "Semgrep", "url": "https://semgrep.dev", "version": "1.52.0", "vendor": {"name": "Semgrep"}},
+			"scanner": {"id": "semgrep", "name": "Semgrep", "url": "https://semgrep.dev", "version": "1.52.0", "vendor": {"name": "Semgrep"}},
+			"version": "1.52.0", "status": "success", "type": "sast"
+		},
+		"vulnerabilities": [{
+			"id": "4c4e7f3a-2a8d-5c1c-9c0e-1b2d3e4f5a6b",
+			"category": "sast",
+			"message": "Detected non-static command inside Command.",
+			"description": "Detected non-static command inside Command.",
+			"cve": "main.go:7f0f0b5c:go.lang.security.audit.dangerous-exec-command",
+			"severity": "High",
+			"scanner": {"id": "semgrep", "name": "Semgrep"},
+			"location": {"file": "main.go", "start_line": 12, "end_line": 12},
+			"identifiers": [
+				{"type": "semgrep_type", "name": "Semgrep - go.lang.security.audit.dangerous-exec-command", "value": "go.lang.security.audit.dangerous-exec-command", "url": "https://semgrep.dev/r/go.lang.security.audit.dangerous-exec-command"},
+				{"type": "cwe", "name": "CWE-78: OS Command Injection", "value": "78", "url": "https://cwe.mitre.org/data/definitions/78.html"}
+			],
+			"details": {}
+		}]
+	}`
+	report, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scan.Type != TypeSAST || report.Scan.Scanner.Version != "1.52.0" {
+		t.Errorf("got scan %+v", report.Scan)
+	}
+	if len(report.Vulnerabilities) != 1 {
+		t.Fatalf("got %d vulnerabilities, want 1", len(report.Vulnerabilities))
+	}
+	v := report.Vulnerabilities[0]
+	if v.Severity != SeverityHigh || v.Location.File != "main.go" || v.Location.StartLine != 12 {
+		t.Errorf("got vulnerability %+v", v)
+	}
+	if len(v.Identifiers) != 2 || v.Identifiers[1].Value != "78" {
+		t.Errorf("got identifiers %+v", v.Identifiers)
+	}
+
+	for _, bad := range []string{`{"version": "15.0.4"}`, `[]`} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%s) didn't return an error", bad)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/parsiya/semgrep_go/output/junit"
+)
+
+// JUnitGroup is what each test case is in Output.ToJUnit.
+type JUnitGroup int
+
+const (
+	// Each rule is a test case.
+	JUnitByRule JUnitGroup = iota
+	// Each file is a test case.
+	JUnitByFile
+)
+
+// ToJUnit converts the output to a JUnit XML report with one test suite named
+// "semgrep". Each rule or file is a test case with a failure for each of its
+// findings. The failure type is the severity, the message has the file, line,
+// rule, and the message of the finding, and the text is the matched code.
+//
+// Test cases without findings pass. With JUnitByFile they are the scanned
+// paths, with JUnitByRule they are the rules in rules_by_engine. Semgrep errors
+// are in the system-err of the test suite.
+//
+//	data, err := out.ToJUnit(output.JUnitByRule).Marshal()
+func (o Output) ToJUnit(group JUnitGroup) junit.TestSuites {
+	key := RuleKey
+	if group == JUnitByFile {
+		key = FileKey
+	}
+
+	// Test cases in the order they were seen.
+	var names []string
+	cases := map[string]*junit.TestCase{}
+	add := func(name string) *junit.TestCase {
+		if c, exists := cases[name]; exists {
+			return c
+		}
+		c := &junit.TestCase{Name: name, Classname: "semgrep"}
+		if group == JUnitByFile {
+			c.File = name
+		}
+		cases[name] = c
+		names = append(names, name)
+		return c
+	}
+
+	for _, r := range o.Results {
+		c := add(key(r)[0])
+		if c.Line == 0 && group == JUnitByFile {
+			c.Line = r.Start.Line
+		}
+		failure := junit.Failure{
+			Type:    string(r.Severity()),
+			Message: fmt.Sprintf("%s:%d: %s: %s", r.FilePath(), r.Start.Line, r.RuleID(), r.Message()),
+		}
+		if r.Extra.Lines != requiresLogin {
+			failure.Text = r.Extra.Lines
+		}
+		c.Failures = append(c.Failures, failure)
+	}
+
+	// Passing test cases are sorted after the failing ones.
+	var passing []string
+	if group == JUnitByFile {
+		for _, p := range o.Paths.Scanned {
+			passing = append(passing, string(p))
+		}
+	} else {
+		for _, r := range o.RulesByEngine {
+			// [rule_id, engine_kind].
+			if len(r) > 0 {
+				if id, ok := r[0].(string); ok {
+					passing = append(passing, id)
+				}
+			}
+		}
+	}
+	sort.Strings(passing)
+	for _, name := range passing {
+		add(name)
+	}
+
+	suite := junit.TestSuite{Name: "semgrep"}
+	for _, name := range names {
+		c := cases[name]
+		suite.Cases = append(suite.Cases, *c)
+		if c.Failed() {
+			suite.Failures++
+		}
+	}
+	suite.Tests = len(suite.Cases)
+
+	var errs []string
+	for _, e := range o.Errors {
+		msg := e.ErrorType().String()
+		if e.Message != nil && *e.Message != "" {
+			msg = *e.Message
+		}
+		errs = append(errs, fmt.Sprintf("[%s] %s", e.ErrorLevel(), msg))
+	}
+	suite.SystemErr = strings.Join(errs, "\n")
+
+	return junit.TestSuites{
+		Name:     "semgrep",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junit.TestSuite{suite},
+	}
+}
@@ -0,0 +1,126 @@
+// Package junit has the types for JUnit XML reports. See
+// output.Output.ToJUnit.
+//
+// There's no official schema. The types follow the format read by Jenkins and
+// written by `semgrep --junit-xml`.
+package junit
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TestSuites is the root element of a report.
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Disabled int         `xml:"disabled,attr"`
+	Time     float64     `xml:"time,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite is a group of test cases.
+type TestSuite struct {
+	XMLName    xml.Name   `xml:"testsuite"`
+	Name       string     `xml:"name,attr"`
+	Tests      int        `xml:"tests,attr"`
+	Failures   int        `xml:"failures,attr"`
+	Errors     int        `xml:"errors,attr"`
+	Skipped    int        `xml:"skipped,attr"`
+	Disabled   int        `xml:"disabled,attr"`
+	Time       float64    `xml:"time,attr"`
+	Timestamp  string     `xml:"timestamp,attr,omitempty"`
+	Hostname   string     `xml:"hostname,attr,omitempty"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	Cases      []TestCase `xml:"testcase"`
+	SystemOut  string     `xml:"system-out,omitempty"`
+	SystemErr  string     `xml:"system-err,omitempty"`
+}
+
+// Property is a name and value in a TestSuite.
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// TestCase is a single test. It fails if it has failures or errors.
+type TestCase struct {
+	Name      string    `xml:"name,attr"`
+	Classname string    `xml:"classname,attr,omitempty"`
+	File      string    `xml:"file,attr,omitempty"`
+	Line      int       `xml:"line,attr,omitempty"`
+	Time      float64   `xml:"time,attr,omitempty"`
+	Failures  []Failure `xml:"failure"`
+	Errors    []Failure `xml:"error"`
+	Skipped   *Skipped  `xml:"skipped"`
+	SystemOut string    `xml:"system-out,omitempty"`
+	SystemErr string    `xml:"system-err,omitempty"`
+}
+
+// Failed returns true if the test case has failures or errors.
+func (c TestCase) Failed() bool {
+	return len(c.Failures) > 0 || len(c.Errors) > 0
+}
+
+// Failure is a failure or an error in a TestCase.
+type Failure struct {
+	Type    string `xml:"type,attr,omitempty"`
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Skipped marks a skipped TestCase.
+type Skipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// Marshal returns the report as indented XML with the XML header.
+func (s TestSuites) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// Parse reads a report, e.g., from `semgrep --junit-xml`. If the root element
+// is a single <testsuite>, it's returned in a TestSuites.
+func Parse(r io.Reader) (TestSuites, error) {
+	var suites TestSuites
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return suites, errors.New("failed to deserialize the JUnit report: no root element")
+		}
+		if err != nil {
+			return suites, fmt.Errorf("failed to deserialize the JUnit report: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuites":
+			err = dec.DecodeElement(&suites, &start)
+		case "testsuite":
+			var suite TestSuite
+			err = dec.DecodeElement(&suite, &start)
+			suites.Suites = []TestSuite{suite}
+			suites.Tests, suites.Failures, suites.Errors = suite.Tests, suite.Failures, suite.Errors
+			suites.Disabled, suites.Time = suite.Disabled, suite.Time
+		default:
+			return suites, fmt.Errorf("failed to deserialize the JUnit report: unexpected root element <%s>", start.Name.Local)
+		}
+		if err != nil {
+			return suites, fmt.Errorf("failed to deserialize the JUnit report: %w", err)
+		}
+		return suites, nil
+	}
+}
@@ -0,0 +1,58 @@
+package junit
+
+import (
+	"strings"
+	"testing"
+)
+
+// Similar to `semgrep --junit-xml`.
+const semgrepJUnit = `<?xml version="1.0" ?>
+<testsuites disabled="0" errors="0" failures="2" tests="2" time="0.0">
+	<testsuite disabled="0" errors="0" failures="2" name="semgrep results" skipped="0" tests="2" time="0">
+		<testcase name="go.lang.security.audit.dangerous-exec-command" classname="cmd/main.go" file="cmd/main.go" line="12">
+			<failure type="ERROR" message="Detected non-static command inside Command.">	cmd := exec.Command(userInput)</failure>
+		</testcase>
+		<testcase name="go.lang.security.audit.crypto.use_of_weak_crypto" classname="pkg/hash.go" file="pkg/hash.go" line="7">
+			<failure type="WARNING" message="Detected MD5 hash algorithm which is considered insecure.">	h := md5.New()</failure>
+		</testcase>
+	</testsuite>
+</testsuites>
+`
+
+func TestParse(t *testing.T) {
+	suites, err := Parse(strings.NewReader(semgrepJUnit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 2 || suites.Failures != 2 || len(suites.Suites) != 1 {
+		t.Fatalf("got %+v", suites)
+	}
+	suite := suites.Suites[0]
+	if suite.Name != "semgrep results" || len(suite.Cases) != 2 {
+		t.Fatalf("got suite %q with %d cases", suite.Name, len(suite.Cases))
+	}
+	c := suite.Cases[0]
+	if c.Name != "go.lang.security.audit.dangerous-exec-command" || c.File != "cmd/main.go" || c.Line != 12 {
+		t.Errorf("got test case %+v", c)
+	}
+	if !c.Failed() || c.Failures[0].Type != "ERROR" || strings.TrimSpace(c.Failures[0].Text) != "cmd := exec.Command(userInput)" {
+		t.Errorf("got failures %+v", c.Failures)
+	}
+}
+
+func TestParse_TestSuite(t *testing.T) {
+	data := `<testsuite name="semgrep" tests="1" failures="0"><testcase name="rule-1"/></testsuite>`
+	suites, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 1 || len(suites.Suites) != 1 || suites.Suites[0].Cases[0].Failed() {
+		t.Errorf("got %+v", suites)
+	}
+
+	for _, bad := range []string{``, `<report/>`, `<testsuites><testsuite>`} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%q) didn't return an error", bad)
+		}
+	}
+}
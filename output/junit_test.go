@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/output/junit"
+)
+
+func TestOutput_ToJUnit(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		group        JUnitGroup
+		wantTests    int
+		wantFailures int
+	}{
+		// No rules_by_engine in the fixture, only the rules with findings.
+		{"by rule", JUnitByRule, 28, 28},
+		// Scanned files without findings pass.
+		{"by file", JUnitByFile, 977, 37},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := out.ToJUnit(tt.group)
+			if report.Tests != tt.wantTests || report.Failures != tt.wantFailures {
+				t.Errorf("got %d tests and %d failures, want %d and %d",
+					report.Tests, report.Failures, tt.wantTests, tt.wantFailures)
+			}
+			suite := report.Suites[0]
+			findings := 0
+			for _, c := range suite.Cases {
+				findings += len(c.Failures)
+			}
+			if findings != 67 {
+				t.Errorf("got %d failures in the test cases, want 67", findings)
+			}
+			if n := strings.Count(suite.SystemErr, "[warn] "); n != 29 {
+				t.Errorf("got %d errors in system-err, want 29", n)
+			}
+
+			// Marshal and parse the report.
+			data, err := report.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := junit.Parse(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed.Suites[0].Cases, suite.Cases) {
+				t.Errorf("the parsed test cases are different")
+			}
+		})
+	}
+
+	// The first failure of contrib.nodejsscan.crypto_node.node_md5.
+	for _, c := range out.ToJUnit(JUnitByRule).Suites[0].Cases {
+		if c.Name != "contrib.nodejsscan.crypto_node.node_md5" {
+			continue
+		}
+		f := c.Failures[0]
+		if f.Type != "WARNING" || !strings.HasPrefix(f.Message, "juice-shop/Gruntfile.js:76: contrib.nodejsscan.crypto_node.node_md5: The MD5") {
+			t.Errorf("got failure %+v", f)
+		}
+		if strings.TrimSpace(f.Text) != "const md5 = crypto.createHash('md5')" {
+			t.Errorf("got text %q", f.Text)
+		}
+	}
+}
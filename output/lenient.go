@@ -0,0 +1,119 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DeserializeLenient deserializes the JSON output element by element. Results,
+// errors, skipped targets, skipped rules, and explanations that don't match the
+// structs are skipped instead of failing the whole output. Use it with Semgrep
+// versions that are outside Compatibility.
+//
+// skipped has one error for every element that was dropped. err is only set if
+// the data is not a JSON object.
+func DeserializeLenient(data []byte) (out Output, skipped []error, err error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return out, nil, fmt.Errorf("failed to deserialize Semgrep's output: %w", err)
+	}
+
+	// decodeList decodes each element of a list with fn.
+	decodeList := func(m map[string]json.RawMessage, field string, fn func(elem json.RawMessage) error) {
+		val, exists := m[field]
+		if !exists {
+			return
+		}
+		var elems []json.RawMessage
+		if err := json.Unmarshal(val, &elems); err != nil {
+			skipped = append(skipped, fmt.Errorf("%s: %w", field, err))
+			return
+		}
+		for i, elem := range elems {
+			if err := fn(elem); err != nil {
+				skipped = append(skipped, fmt.Errorf("%s[%d]: %w", field, i, err))
+			}
+		}
+	}
+	// decodeField decodes a single field into v. Returns false if the field
+	// doesn't exist or couldn't be decoded.
+	decodeField := func(m map[string]json.RawMessage, field string, v interface{}) bool {
+		val, exists := m[field]
+		if !exists {
+			return false
+		}
+		if err := json.Unmarshal(val, v); err != nil {
+			skipped = append(skipped, fmt.Errorf("%s: %w", field, err))
+			return false
+		}
+		return true
+	}
+
+	decodeList(raw, "results", func(elem json.RawMessage) error {
+		var m CliMatch
+		if err := json.Unmarshal(elem, &m); err != nil {
+			return err
+		}
+		out.Results = append(out.Results, m)
+		return nil
+	})
+	decodeList(raw, "errors", func(elem json.RawMessage) error {
+		var e CliError
+		if err := json.Unmarshal(elem, &e); err != nil {
+			return err
+		}
+		out.Errors = append(out.Errors, e)
+		return nil
+	})
+	decodeList(raw, "skipped_rules", func(elem json.RawMessage) error {
+		var r SkippedRule
+		if err := json.Unmarshal(elem, &r); err != nil {
+			return err
+		}
+		out.SkippedRules = append(out.SkippedRules, r)
+		return nil
+	})
+	decodeList(raw, "explanations", func(elem json.RawMessage) error {
+		var e MatchingExplanation
+		if err := json.Unmarshal(elem, &e); err != nil {
+			return err
+		}
+		out.Explanations = append(out.Explanations, e)
+		return nil
+	})
+	decodeList(raw, "rules_by_engine", func(elem json.RawMessage) error {
+		var r RuleIdAndEngineKind
+		if err := json.Unmarshal(elem, &r); err != nil {
+			return err
+		}
+		out.RulesByEngine = append(out.RulesByEngine, r)
+		return nil
+	})
+
+	// Paths are an object with two lists.
+	if val, exists := raw["paths"]; exists {
+		var paths map[string]json.RawMessage
+		if err := json.Unmarshal(val, &paths); err != nil {
+			skipped = append(skipped, fmt.Errorf("paths: %w", err))
+		} else {
+			decodeField(paths, "scanned", &out.Paths.Scanned)
+			decodeList(paths, "skipped", func(elem json.RawMessage) error {
+				var s SkippedTarget
+				if err := json.Unmarshal(elem, &s); err != nil {
+					return err
+				}
+				out.Paths.Skipped = append(out.Paths.Skipped, s)
+				return nil
+			})
+		}
+	}
+
+	decodeField(raw, "version", &out.Version)
+	decodeField(raw, "engine_requested", &out.EngineRequested)
+	var profile Profile
+	if decodeField(raw, "time", &profile) {
+		out.Time = &profile
+	}
+
+	return out, skipped, nil
+}
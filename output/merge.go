@@ -0,0 +1,224 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Merge combines the outputs of multiple scans, e.g., shards of a monorepo,
+// into one output:
+//   - Results are concatenated. Identical findings (same rule, path, and
+//     position) are only added once.
+//   - Scanned paths, skipped targets, errors, skipped rules, and
+//     rules_by_engine are unions in the order they were seen.
+//   - Time profiles are combined. Rules are a union, targets are concatenated
+//     and their per rule times are moved to the new rule indexes. Times and
+//     byte counts are added, max memory is the largest one.
+//   - If the versions are different, the lowest one is used and a warning is
+//     logged.
+func Merge(outs ...Output) Output {
+	var merged Output
+	var (
+		results  = set{}
+		scanned  = set{}
+		skipped  = set{}
+		errs     = set{}
+		skipRule = set{}
+		engines  = set{}
+		versions []Version
+	)
+
+	for _, out := range outs {
+		for _, r := range out.Results {
+			if results.add(resultKey(r)) {
+				merged.Results = append(merged.Results, r)
+			}
+		}
+		for _, p := range out.Paths.Scanned {
+			if scanned.add(string(p)) {
+				merged.Paths.Scanned = append(merged.Paths.Scanned, p)
+			}
+		}
+		for _, s := range out.Paths.Skipped {
+			if skipped.add(jsonKey(s)) {
+				merged.Paths.Skipped = append(merged.Paths.Skipped, s)
+			}
+		}
+		for _, e := range out.Errors {
+			if errs.add(jsonKey(e)) {
+				merged.Errors = append(merged.Errors, e)
+			}
+		}
+		for _, s := range out.SkippedRules {
+			if skipRule.add(jsonKey(s)) {
+				merged.SkippedRules = append(merged.SkippedRules, s)
+			}
+		}
+		for _, r := range out.RulesByEngine {
+			if engines.add(jsonKey(r)) {
+				merged.RulesByEngine = append(merged.RulesByEngine, r)
+			}
+		}
+		merged.Explanations = append(merged.Explanations, out.Explanations...)
+		if merged.EngineRequested == nil {
+			merged.EngineRequested = out.EngineRequested
+		}
+		if out.Time != nil {
+			merged.Time = mergeProfiles(merged.Time, out.Time)
+		}
+		if out.Version != nil {
+			versions = append(versions, *out.Version)
+		}
+	}
+
+	merged.Version = mergeVersions(versions)
+	return merged
+}
+
+// set is a set of strings.
+type set map[string]bool
+
+// add adds the key and returns true if it was not in the set.
+func (s set) add(key string) bool {
+	if s[key] {
+		return false
+	}
+	s[key] = true
+	return true
+}
+
+// resultKey identifies a finding.
+func resultKey(c CliMatch) string {
+	return fmt.Sprintf("%s\x00%s\x00%d:%d:%d\x00%d:%d:%d", c.RuleID(), c.FilePath(),
+		c.Start.Line, c.Start.Col, c.Start.Offset, c.End.Line, c.End.Col, c.End.Offset)
+}
+
+// jsonKey returns v in JSON to compare values without a natural key.
+func jsonKey(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return string(data)
+}
+
+// mergeProfiles adds b to a and returns the result. a can be nil.
+func mergeProfiles(a, b *Profile) *Profile {
+	p := &Profile{ProfilingTimes: ProfileProfilingTimes{}}
+	if a != nil {
+		*p = *a
+		p.Rules = append([]RuleId(nil), a.Rules...)
+		p.Targets = append([]TargetTimes(nil), a.Targets...)
+		p.ProfilingTimes = ProfileProfilingTimes{}
+		for k, v := range a.ProfilingTimes {
+			p.ProfilingTimes[k] = v
+		}
+	}
+	index := make(map[RuleId]int, len(p.Rules))
+	for i, r := range p.Rules {
+		index[r] = i
+	}
+
+	// Map the rule indexes in b to the merged ones.
+	remap := make([]int, len(b.Rules))
+	for i, r := range b.Rules {
+		n, ok := index[r]
+		if !ok {
+			n = len(p.Rules)
+			index[r] = n
+			p.Rules = append(p.Rules, r)
+		}
+		remap[i] = n
+	}
+	// Targets from a need times for the new rules.
+	for i, t := range p.Targets {
+		t.MatchTimes = resize(t.MatchTimes, len(p.Rules))
+		t.ParseTimes = resize(t.ParseTimes, len(p.Rules))
+		p.Targets[i] = t
+	}
+	for _, t := range b.Targets {
+		t.MatchTimes = moveTimes(t.MatchTimes, remap, len(p.Rules))
+		t.ParseTimes = moveTimes(t.ParseTimes, remap, len(p.Rules))
+		p.Targets = append(p.Targets, t)
+	}
+
+	for k, v := range b.ProfilingTimes {
+		p.ProfilingTimes[k] += v
+	}
+	p.RulesParseTime += b.RulesParseTime
+	p.TotalBytes += b.TotalBytes
+	if b.MaxMemoryBytes != nil && (p.MaxMemoryBytes == nil || *b.MaxMemoryBytes > *p.MaxMemoryBytes) {
+		m := *b.MaxMemoryBytes
+		p.MaxMemoryBytes = &m
+	}
+	return p
+}
+
+// resize returns a copy of times with n items.
+func resize(times []float64, n int) []float64 {
+	if times == nil {
+		return nil
+	}
+	resized := make([]float64, n)
+	copy(resized, times)
+	return resized
+}
+
+// moveTimes returns per rule times with the rule at index i moved to
+// remap[i].
+func moveTimes(times []float64, remap []int, n int) []float64 {
+	if times == nil {
+		return nil
+	}
+	moved := make([]float64, n)
+	for i, t := range times {
+		if i < len(remap) {
+			moved[remap[i]] = t
+		}
+	}
+	return moved
+}
+
+// mergeVersions returns the lowest version. It logs a warning if the versions
+// are different.
+func mergeVersions(versions []Version) *Version {
+	if len(versions) == 0 {
+		return nil
+	}
+	lowest := versions[0]
+	different := false
+	for _, v := range versions[1:] {
+		if v == lowest {
+			continue
+		}
+		different = true
+		if versionLess(v, lowest) {
+			lowest = v
+		}
+	}
+	if different {
+		unique := []string{}
+		seen := set{}
+		for _, v := range versions {
+			if seen.add(string(v)) {
+				unique = append(unique, string(v))
+			}
+		}
+		log.Printf("Warning: merging outputs from different Semgrep versions (%s), using %s",
+			strings.Join(unique, ", "), lowest)
+	}
+	return &lowest
+}
+
+// versionLess compares two versions. Versions that cannot be parsed are
+// compared as strings.
+func versionLess(a, b Version) bool {
+	va, errA := a.Parse()
+	vb, errB := b.Parse()
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return va.Less(vb)
+}
@@ -0,0 +1,94 @@
+package output
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two overlapping shards.
+	a, b := out, out
+	a.Results = out.Results[:40]
+	b.Results = out.Results[30:]
+	a.Paths.Scanned = out.Paths.Scanned[:500]
+	b.Paths.Scanned = out.Paths.Scanned[400:]
+	a.Errors = out.Errors[:20]
+	b.Errors = out.Errors[10:]
+	newer := Version("1.50.0")
+	b.Version = &newer
+
+	mem1, mem2 := 100, 200
+	a.Time = &Profile{
+		Rules:          []RuleId{"r1", "r2"},
+		RulesParseTime: 1,
+		ProfilingTimes: ProfileProfilingTimes{"total_time": 2},
+		Targets:        []TargetTimes{{Path: "a.js", MatchTimes: []float64{0.1, 0.2}, ParseTimes: []float64{0.3, 0.4}}},
+		TotalBytes:     10,
+		MaxMemoryBytes: &mem1,
+	}
+	b.Time = &Profile{
+		Rules:          []RuleId{"r3", "r1"},
+		RulesParseTime: 2,
+		ProfilingTimes: ProfileProfilingTimes{"total_time": 3, "config_time": 1},
+		Targets:        []TargetTimes{{Path: "b.js", MatchTimes: []float64{0.5, 0.6}, ParseTimes: []float64{0.7, 0.8}}},
+		TotalBytes:     20,
+		MaxMemoryBytes: &mem2,
+	}
+
+	var logs bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&logs)
+	got := Merge(a, b)
+	log.SetOutput(prev)
+
+	if !reflect.DeepEqual(got.Results, out.Results) {
+		t.Errorf("Merge() got %d results, want %d", len(got.Results), len(out.Results))
+	}
+	if !reflect.DeepEqual(got.Paths.Scanned, out.Paths.Scanned) {
+		t.Errorf("Merge() got %d scanned paths, want %d", len(got.Paths.Scanned), len(out.Paths.Scanned))
+	}
+	if !reflect.DeepEqual(got.Errors, out.Errors) {
+		t.Errorf("Merge() got %d errors, want %d", len(got.Errors), len(out.Errors))
+	}
+
+	if got.Version == nil || *got.Version != *out.Version {
+		t.Errorf("Merge() version = %v, want the lowest version %v", got.Version, *out.Version)
+	}
+	if !strings.Contains(logs.String(), "different Semgrep versions (1.42.0, 1.50.0)") {
+		t.Errorf("Merge() didn't log a warning for different versions: %q", logs.String())
+	}
+
+	wantTime := &Profile{
+		Rules:          []RuleId{"r1", "r2", "r3"},
+		RulesParseTime: 3,
+		ProfilingTimes: ProfileProfilingTimes{"total_time": 5, "config_time": 1},
+		Targets: []TargetTimes{
+			{Path: "a.js", MatchTimes: []float64{0.1, 0.2, 0}, ParseTimes: []float64{0.3, 0.4, 0}},
+			{Path: "b.js", MatchTimes: []float64{0.6, 0, 0.5}, ParseTimes: []float64{0.8, 0, 0.7}},
+		},
+		TotalBytes:     30,
+		MaxMemoryBytes: &mem2,
+	}
+	if !reflect.DeepEqual(got.Time, wantTime) {
+		t.Errorf("Merge() time = %+v, want %+v", got.Time, wantTime)
+	}
+	// The inputs must not change.
+	if len(a.Time.Targets[0].MatchTimes) != 2 || a.Time.ProfilingTimes["total_time"] != 2 {
+		t.Errorf("Merge() modified the input profile")
+	}
+}
+
+func TestMerge_Empty(t *testing.T) {
+	got := Merge()
+	if got.Version != nil || got.Results != nil || got.Time != nil {
+		t.Errorf("Merge() = %+v, want an empty output", got)
+	}
+}
@@ -0,0 +1,656 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/parsiya/semgrep_go/output/enum"
+	"github.com/parsiya/semgrep_go/output/sarif"
+)
+
+// The fingerprint key used by Semgrep in SARIF.
+const sarifFingerprint = "matchBasedId/v1"
+
+// ToSARIF converts the output to a SARIF 2.1.0 log that is similar to the one
+// from `semgrep --sarif`. Marshal it with encoding/json to upload it to GitHub
+// code scanning.
+//   - Rules are created from the results. The tags come from the cwe, owasp,
+//     confidence, and category metadata fields.
+//   - Taint results have a codeFlow from the dataflow trace.
+//   - Extra.Fix is converted to a fix and ignored results are suppressed.
+//   - Errors are tool execution notifications.
+//   - Paths are percent-encoded URIs. Relative paths are relative to
+//     %SRCROOT% and absolute paths are `file://` URIs.
+func (o Output) ToSARIF() sarif.Log {
+	driver := sarif.ToolComponent{
+		Name:           "Semgrep OSS",
+		InformationURI: "https://semgrep.dev",
+	}
+	if enum.ParseEngineKind(o.EngineRequested) == enum.EnginePro {
+		driver.Name = "Semgrep PRO"
+	}
+	if o.Version != nil {
+		driver.SemanticVersion = string(*o.Version)
+	}
+
+	results := make([]sarif.Result, 0, len(o.Results))
+	rules := map[string]sarif.ReportingDescriptor{}
+	for _, r := range o.Results {
+		results = append(results, sarifResult(r))
+		if _, exists := rules[r.RuleID()]; !exists {
+			rules[r.RuleID()] = sarifRule(r)
+		}
+	}
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		driver.Rules = append(driver.Rules, rules[id])
+	}
+
+	inv := sarif.Invocation{ExecutionSuccessful: true}
+	for _, e := range o.Errors {
+		inv.ToolExecutionNotifications = append(inv.ToolExecutionNotifications, sarifNotification(e))
+	}
+
+	return sarif.Log{
+		Schema:  sarif.Schema,
+		Version: sarif.Version,
+		Runs: []sarif.Run{{
+			Tool:        sarif.Tool{Driver: driver},
+			Invocations: []sarif.Invocation{inv},
+			Results:     results,
+		}},
+	}
+}
+
+// sarifLevel converts a Semgrep severity to a SARIF level.
+func sarifLevel(sev enum.Severity) string {
+	switch sev {
+	case enum.SeverityError, enum.SeverityCritical, enum.SeverityHigh:
+		return sarif.LevelError
+	case enum.SeverityWarning, enum.SeverityMedium:
+		return sarif.LevelWarning
+	}
+	return sarif.LevelNote
+}
+
+// sarifRule creates a rule from the metadata of a result.
+func sarifRule(c CliMatch) sarif.ReportingDescriptor {
+	rule := sarif.ReportingDescriptor{
+		ID:                   c.RuleID(),
+		Name:                 c.RuleID(),
+		ShortDescription:     &sarif.MultiformatMessage{Text: "Semgrep Finding: " + c.RuleID()},
+		FullDescription:      &sarif.MultiformatMessage{Text: c.Message()},
+		DefaultConfiguration: &sarif.ReportingConfiguration{Level: sarifLevel(c.Severity())},
+		Properties:           sarif.PropertyBag{},
+	}
+
+	// Help has the message and the references.
+	help := sarif.MultiformatMessage{Text: c.Message(), Markdown: c.Message()}
+	if refs := metadataValues(c, "references"); len(refs) > 0 {
+		help.Markdown += "\n\n<b>References:</b>\n"
+		for _, ref := range refs {
+			help.Markdown += fmt.Sprintf(" - [%s](%s)\n", ref, ref)
+		}
+	}
+	rule.Help = &help
+	for _, field := range []string{"source", "shortlink"} {
+		if v := metadataValues(c, field); len(v) > 0 {
+			rule.HelpURI = v[0]
+			break
+		}
+	}
+
+	tags := []string{}
+	tags = append(tags, metadataValues(c, "cwe")...)
+	for _, owasp := range metadataValues(c, "owasp") {
+		tags = append(tags, "OWASP-"+owasp)
+	}
+	if confidence := metadataValues(c, "confidence"); len(confidence) > 0 {
+		tags = append(tags, confidence[0]+" CONFIDENCE")
+		rule.Properties["precision"] = sarifPrecision(confidence[0])
+	}
+	tags = append(tags, metadataValues(c, "category")...)
+	rule.Properties["tags"] = tags
+	return rule
+}
+
+// sarifPrecision converts the confidence metadata to a SARIF precision.
+func sarifPrecision(confidence string) string {
+	switch strings.ToUpper(confidence) {
+	case "HIGH":
+		return "high"
+	case "MEDIUM":
+		return "medium"
+	case "LOW":
+		return "low"
+	}
+	return "very-high"
+}
+
+// sarifResult converts a result.
+func sarifResult(c CliMatch) sarif.Result {
+	region := sarifRegion(c.Start, c.End)
+	if c.Extra.Lines != "" && c.Extra.Lines != requiresLogin {
+		region.Snippet = &sarif.ArtifactContent{Text: c.Extra.Lines}
+	}
+	res := sarif.Result{
+		RuleID:    c.RuleID(),
+		Level:     sarifLevel(c.Severity()),
+		Message:   sarif.Message{Text: c.Message()},
+		Locations: []sarif.Location{sarifLocation(c.FilePath(), region)},
+	}
+	if fp := c.Extra.Fingerprint; fp != "" && fp != requiresLogin {
+		res.Fingerprints = map[string]string{sarifFingerprint: fp}
+	}
+	if c.Extra.DataflowTrace != nil {
+		if flow, ok := sarifCodeFlow(*c.Extra.DataflowTrace); ok {
+			res.CodeFlows = []sarif.CodeFlow{flow}
+		}
+	}
+	if c.Extra.Fix != nil {
+		res.Fixes = []sarif.Fix{{
+			Description: &sarif.Message{Text: c.Message() + "\n Autofix: Semgrep rule suggested fix"},
+			ArtifactChanges: []sarif.ArtifactChange{{
+				ArtifactLocation: sarifArtifact(c.FilePath()),
+				Replacements: []sarif.Replacement{{
+					DeletedRegion:   *sarifRegion(c.Start, c.End),
+					InsertedContent: &sarif.ArtifactContent{Text: *c.Extra.Fix},
+				}},
+			}},
+		}}
+	}
+	if c.Extra.IsIgnored != nil && *c.Extra.IsIgnored {
+		res.Suppressions = []sarif.Suppression{{Kind: "inSource"}}
+	}
+	return res
+}
+
+// sarifArtifact returns the artifact location of a path. Relative paths are
+// relative to the root of the scan and absolute paths, including Windows paths
+// with a drive letter, are `file://` URIs.
+func sarifArtifact(path string) sarif.ArtifactLocation {
+	p := filepath.ToSlash(path)
+	if len(p) > 2 && isDrive(p[:2]) && p[2] == '/' {
+		// `C:/src/x.go` is `file:///C:/src/x.go`.
+		p = "/" + p
+	}
+	if strings.HasPrefix(p, "/") {
+		return sarif.ArtifactLocation{URI: "file://" + escapeURIPath(p)}
+	}
+	return sarif.ArtifactLocation{URI: escapeURIPath(p), URIBaseID: sarif.SrcRoot}
+}
+
+// escapeURIPath percent-encodes a slash-separated path so it can be used as a
+// URI reference. A colon in the first segment of a relative path is escaped so
+// it's not read as a scheme. fromSARIFURI reverses it.
+func escapeURIPath(p string) string {
+	esc := (&url.URL{Path: p}).EscapedPath()
+	first := esc
+	if i := strings.IndexByte(esc, '/'); i >= 0 {
+		first = esc[:i]
+	}
+	return strings.ReplaceAll(first, ":", "%3A") + esc[len(first):]
+}
+
+func sarifLocation(path string, region *sarif.Region) sarif.Location {
+	return sarif.Location{PhysicalLocation: &sarif.PhysicalLocation{
+		ArtifactLocation: sarifArtifact(path),
+		Region:           region,
+	}}
+}
+
+func sarifRegion(start, end Position) *sarif.Region {
+	return &sarif.Region{
+		StartLine:   start.Line,
+		StartColumn: start.Col,
+		EndLine:     end.Line,
+		EndColumn:   end.Col,
+	}
+}
+
+// sarifCodeFlow converts a dataflow trace to a code flow from the source,
+// through the intermediate variables, to the sink. Returns false if the trace
+// has no locations.
+func sarifCodeFlow(trace MatchDataflowTrace) (sarif.CodeFlow, bool) {
+	var flow sarif.ThreadFlow
+	add := func(kind string, v MatchIntermediateVar, nesting int) {
+		loc := sarifLocation(string(v.Location.Path), sarifRegion(v.Location.Start, v.Location.End))
+		loc.Message = &sarif.Message{Text: fmt.Sprintf("%s: '%s' @ '%s:%d'",
+			kind, v.Content, v.Location.Path, v.Location.Start.Line)}
+		flow.Locations = append(flow.Locations, sarif.ThreadFlowLocation{
+			Location: &loc, NestingLevel: nesting,
+		})
+	}
+
+	source, hasSource := traceLocation(trace.TaintSource)
+	sink, hasSink := traceLocation(trace.TaintSink)
+	if hasSource {
+		add("Source", source, 0)
+	}
+	for _, v := range trace.IntermediateVars {
+		add("Propagator", v, 0)
+	}
+	if hasSink {
+		add("Sink", sink, 1)
+	}
+	if len(flow.Locations) == 0 {
+		return sarif.CodeFlow{}, false
+	}
+
+	cf := sarif.CodeFlow{ThreadFlows: []sarif.ThreadFlow{flow}}
+	if hasSource && hasSink {
+		cf.Message = &sarif.Message{Text: fmt.Sprintf("Untrusted dataflow from %s:%d to %s:%d",
+			source.Location.Path, source.Location.Start.Line, sink.Location.Path, sink.Location.Start.Line)}
+	}
+	return cf, true
+}
+
+// traceLocation returns the location of a taint source or sink. They are
+// either ["CliLoc", [location, content]] or ["CliCall", [[location, content],
+// intermediate_vars, trace]]. For a call, it's the location of the call.
+func traceLocation(v interface{}) (MatchIntermediateVar, bool) {
+	tag, payload := traceVariant(v)
+	switch tag {
+	case "CliLoc":
+		return traceLoc(payload)
+	case "CliCall":
+		var call []json.RawMessage
+		if err := json.Unmarshal(payload, &call); err != nil || len(call) == 0 {
+			return MatchIntermediateVar{}, false
+		}
+		return traceLoc(call[0])
+	}
+	return MatchIntermediateVar{}, false
+}
+
+// traceVariant returns the tag and the payload of ["Tag", payload].
+func traceVariant(v interface{}) (string, json.RawMessage) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", nil
+	}
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil || len(pair) != 2 {
+		return "", nil
+	}
+	var tag string
+	if err := json.Unmarshal(pair[0], &tag); err != nil {
+		return "", nil
+	}
+	return tag, pair[1]
+}
+
+// traceLoc decodes [location, content].
+func traceLoc(data json.RawMessage) (MatchIntermediateVar, bool) {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil || len(pair) != 2 {
+		return MatchIntermediateVar{}, false
+	}
+	var v MatchIntermediateVar
+	if err := json.Unmarshal(pair[0], &v.Location); err != nil {
+		return v, false
+	}
+	if err := json.Unmarshal(pair[1], &v.Content); err != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// sarifNotification converts an error.
+func sarifNotification(e CliError) sarif.Notification {
+	n := sarif.Notification{
+		Descriptor: &sarif.ReportingDescriptorReference{ID: e.ErrorType().String()},
+		Level:      sarif.LevelNote,
+	}
+	switch e.ErrorLevel() {
+	case enum.LevelError:
+		n.Level = sarif.LevelError
+	case enum.LevelWarn:
+		n.Level = sarif.LevelWarning
+	}
+	for _, msg := range []*string{e.Message, e.LongMsg, e.ShortMsg} {
+		if msg != nil && *msg != "" {
+			n.Message.Text = *msg
+			break
+		}
+	}
+	if n.Message.Text == "" {
+		n.Message.Text = e.ErrorType().String()
+	}
+	for _, span := range e.Spans {
+		// A region needs a start line.
+		var region *sarif.Region
+		if span.Start.Line > 0 {
+			region = sarifRegion(span.Start, span.End)
+		}
+		n.Locations = append(n.Locations, sarifLocation(string(span.File), region))
+	}
+	if len(n.Locations) == 0 && e.Path != nil {
+		n.Locations = []sarif.Location{sarifLocation(string(*e.Path), nil)}
+	}
+	return n
+}
+
+// ----------
+
+// FromSARIF reads a SARIF log, e.g., from `semgrep --sarif`, and converts it
+// to an Output so it can be used like Semgrep's JSON output.
+//   - Results get the rule id, path, positions, message, fingerprint, fix,
+//     and the code from the snippet. The severity comes from the result or
+//     rule level: error is ERROR, warning is WARNING, and the rest are INFO.
+//   - Metadata is created from the rule properties. Tags are converted back to
+//     cwe, owasp, confidence, and category. helpUri is source and the links in
+//     the help are references. Other properties are copied as-is.
+//   - Notifications are errors. SARIF doesn't have the error codes so Code is
+//     zero.
+//   - Scanned paths are the paths with results.
+//
+// The results of all runs are added to the output. The version is from the
+// first run.
+func FromSARIF(r io.Reader) (Output, error) {
+	var log sarif.Log
+	if err := json.NewDecoder(r).Decode(&log); err != nil {
+		return Output{}, fmt.Errorf("failed to deserialize the SARIF log: %w", err)
+	}
+	if log.Version != sarif.Version {
+		return Output{}, fmt.Errorf("unsupported SARIF version: %q", log.Version)
+	}
+
+	out := Output{
+		Errors:  []CliError{},
+		Paths:   ScannedAndSkipped{Scanned: []Fpath{}},
+		Results: []CliMatch{},
+	}
+	scanned := set{}
+	for _, run := range log.Runs {
+		if out.Version == nil && run.Tool.Driver.SemanticVersion != "" {
+			v := Version(run.Tool.Driver.SemanticVersion)
+			out.Version = &v
+		}
+		rules := make(map[string]sarif.ReportingDescriptor, len(run.Tool.Driver.Rules))
+		for _, rule := range run.Tool.Driver.Rules {
+			rules[rule.ID] = rule
+		}
+
+		for _, res := range run.Results {
+			c := fromSARIFResult(res, rules[res.RuleID])
+			out.Results = append(out.Results, c)
+			if scanned.add(c.FilePath()) {
+				out.Paths.Scanned = append(out.Paths.Scanned, c.Path)
+			}
+		}
+		for _, inv := range run.Invocations {
+			for _, n := range inv.ToolExecutionNotifications {
+				out.Errors = append(out.Errors, fromSARIFNotification(n))
+			}
+		}
+	}
+	return out, nil
+}
+
+// fromSARIFResult converts a result. rule is empty if the result's rule is not
+// in the log.
+func fromSARIFResult(res sarif.Result, rule sarif.ReportingDescriptor) CliMatch {
+	c := CliMatch{
+		CheckId: RuleId(res.RuleID),
+		Extra: CliMatchExtra{
+			Message:  res.Message.Text,
+			Metadata: fromSARIFMetadata(rule),
+		},
+	}
+
+	level := res.Level
+	if level == "" && rule.DefaultConfiguration != nil {
+		level = rule.DefaultConfiguration.Level
+	}
+	c.Extra.Severity = string(fromSARIFLevel(level))
+
+	if len(res.Locations) > 0 && res.Locations[0].PhysicalLocation != nil {
+		phys := res.Locations[0].PhysicalLocation
+		c.Path = Fpath(fromSARIFURI(phys.ArtifactLocation.URI))
+		if phys.Region != nil {
+			c.Start, c.End = fromSARIFRegion(*phys.Region)
+			if phys.Region.Snippet != nil {
+				c.Extra.Lines = phys.Region.Snippet.Text
+			}
+		}
+	}
+
+	// Use Semgrep's fingerprint or the first one in order.
+	if fp, ok := res.Fingerprints[sarifFingerprint]; ok {
+		c.Extra.Fingerprint = fp
+	} else if len(res.Fingerprints) > 0 {
+		keys := make([]string, 0, len(res.Fingerprints))
+		for k := range res.Fingerprints {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		c.Extra.Fingerprint = res.Fingerprints[keys[0]]
+	}
+
+	if len(res.Fixes) > 0 && len(res.Fixes[0].ArtifactChanges) > 0 {
+		if reps := res.Fixes[0].ArtifactChanges[0].Replacements; len(reps) > 0 && reps[0].InsertedContent != nil {
+			fix := reps[0].InsertedContent.Text
+			c.Extra.Fix = &fix
+		}
+	}
+	if len(res.Suppressions) > 0 {
+		ignored := true
+		c.Extra.IsIgnored = &ignored
+	}
+	return c
+}
+
+// fromSARIFLevel converts a SARIF level to a Semgrep severity.
+func fromSARIFLevel(level string) enum.Severity {
+	switch level {
+	case sarif.LevelError:
+		return enum.SeverityError
+	case sarif.LevelWarning, "":
+		// warning is the default level in SARIF.
+		return enum.SeverityWarning
+	}
+	return enum.SeverityInfo
+}
+
+// fromSARIFRegion returns the start and end positions of a region. The end is
+// the start if it's not set.
+func fromSARIFRegion(r sarif.Region) (start, end Position) {
+	start = Position{Line: r.StartLine, Col: r.StartColumn}
+	end = Position{Line: r.EndLine, Col: r.EndColumn}
+	if end.Line == 0 {
+		end.Line = start.Line
+	}
+	if end.Col == 0 {
+		end.Col = start.Col
+	}
+	return start, end
+}
+
+// fromSARIFURI returns the path in an artifact URI. It reverses sarifArtifact:
+// `file://` is removed and the path is unescaped. Windows paths keep their
+// drive letter, e.g., `file:///C:/src/x.go` is `C:/src/x.go`. URIs with other
+// schemes are returned as-is.
+func fromSARIFURI(uri string) string {
+	p := uri
+	switch scheme := uriScheme(uri); {
+	case strings.EqualFold(scheme, "file"):
+		// `file://host/share/x.go` is kept as the UNC path `//host/share/x.go`.
+		p = uri[len("file:"):]
+		if strings.HasPrefix(p, "///") || strings.HasPrefix(p, "//") && len(p) > 4 && isDrive(p[2:4]) {
+			// `file:///src/x.go` and `file://C:/src/x.go`.
+			p = p[2:]
+		}
+	case len(scheme) > 1:
+		return uri
+	}
+	p = unescapeURIPath(p)
+	// `/C:/src/x.go`.
+	if len(p) > 2 && p[0] == '/' && isDrive(p[1:3]) {
+		p = p[1:]
+	}
+	return p
+}
+
+// uriScheme returns the scheme of a URI or an empty string if it doesn't have
+// one. A drive letter like `C:` is a one letter scheme.
+func uriScheme(uri string) string {
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		switch {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		case i > 0 && c == ':':
+			return uri[:i]
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// unescapeURIPath decodes the percent-encoded bytes in p. Unlike
+// url.PathUnescape, a `%` that is not followed by two hex digits is kept, so
+// unescaped paths from other tools are not lost.
+func unescapeURIPath(p string) string {
+	if !strings.Contains(p, "%") {
+		return p
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '%' && i+2 < len(p) && isHex(p[i+1]) && isHex(p[i+2]) {
+			b.WriteByte(unhex(p[i+1])<<4 | unhex(p[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// isDrive returns true if s is a Windows drive like `C:`.
+func isDrive(s string) bool {
+	return len(s) == 2 && s[1] == ':' &&
+		('a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z')
+}
+
+// Links in the help Markdown created by ToSARIF and Semgrep.
+var sarifReference = regexp.MustCompile(`(?m)^\s*-\s*\[[^\]]*\]\(([^)]+)\)`)
+
+// fromSARIFMetadata creates the metadata from the rule properties.
+func fromSARIFMetadata(rule sarif.ReportingDescriptor) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	for k, v := range rule.Properties {
+		if k != "tags" && k != "precision" {
+			metadata[k] = v
+		}
+	}
+
+	var cwe, owasp, category []interface{}
+	tags, _ := rule.Properties["tags"].([]interface{})
+	for _, tag := range tags {
+		s, ok := tag.(string)
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(s, "CWE-"):
+			cwe = append(cwe, s)
+		case strings.HasPrefix(s, "OWASP-"):
+			owasp = append(owasp, strings.TrimPrefix(s, "OWASP-"))
+		case strings.HasSuffix(s, " CONFIDENCE"):
+			metadata["confidence"] = strings.TrimSuffix(s, " CONFIDENCE")
+		default:
+			category = append(category, s)
+		}
+	}
+	if cwe != nil {
+		metadata["cwe"] = cwe
+	}
+	if owasp != nil {
+		metadata["owasp"] = owasp
+	}
+	switch len(category) {
+	case 0:
+	case 1:
+		metadata["category"] = category[0]
+	default:
+		metadata["category"] = category
+	}
+	if _, exists := metadata["confidence"]; !exists {
+		if p, ok := rule.Properties["precision"].(string); ok && p != "very-high" {
+			metadata["confidence"] = strings.ToUpper(p)
+		}
+	}
+
+	if rule.HelpURI != "" {
+		metadata["source"] = rule.HelpURI
+	}
+	if rule.Help != nil {
+		var refs []interface{}
+		for _, m := range sarifReference.FindAllStringSubmatch(rule.Help.Markdown, -1) {
+			refs = append(refs, m[1])
+		}
+		if refs != nil {
+			metadata["references"] = refs
+		}
+	}
+	return metadata
+}
+
+// fromSARIFNotification converts a notification to an error.
+func fromSARIFNotification(n sarif.Notification) CliError {
+	e := CliError{Level: string(enum.LevelInfo)}
+	switch n.Level {
+	case sarif.LevelError:
+		e.Level = string(enum.LevelError)
+	case sarif.LevelWarning, "":
+		e.Level = string(enum.LevelWarn)
+	}
+	if n.Descriptor != nil {
+		e.Type = n.Descriptor.ID
+	}
+	if n.Message.Text != "" {
+		msg := n.Message.Text
+		e.Message = &msg
+	}
+	for _, loc := range n.Locations {
+		if loc.PhysicalLocation == nil {
+			continue
+		}
+		path := Fpath(fromSARIFURI(loc.PhysicalLocation.ArtifactLocation.URI))
+		if e.Path == nil {
+			e.Path = &path
+		}
+		if r := loc.PhysicalLocation.Region; r != nil {
+			span := ErrorSpan{File: path}
+			span.Start, span.End = fromSARIFRegion(*r)
+			e.Spans = append(e.Spans, span)
+		}
+	}
+	return e
+}
@@ -0,0 +1,189 @@
+// Package sarif has the types for the parts of SARIF 2.1.0 used by Semgrep.
+// See output.Output.ToSARIF and output.FromSARIF.
+//
+// The spec is at
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/os/sarif-v2.1.0-os.html.
+package sarif
+
+const (
+	// Version is the SARIF version.
+	Version = "2.1.0"
+	// Schema is the URI of the SARIF 2.1.0 JSON schema.
+	Schema = "https://docs.oasis-open.org/sarif/sarif/v2.1.0/os/schemas/sarif-schema-2.1.0.json"
+)
+
+// Result and notification levels.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+	LevelNone    = "none"
+)
+
+// SrcRoot is the uriBaseId for paths relative to the root of the scan.
+const SrcRoot = "%SRCROOT%"
+
+// Log is the top-level SARIF object.
+type Log struct {
+	Schema  string `json:"$schema,omitempty"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is a single run of a tool.
+type Run struct {
+	Tool        Tool         `json:"tool"`
+	Invocations []Invocation `json:"invocations,omitempty"`
+	Results     []Result     `json:"results"`
+}
+
+// Tool is the tool that produced the run.
+type Tool struct {
+	Driver ToolComponent `json:"driver"`
+}
+
+// ToolComponent describes the tool and its rules.
+type ToolComponent struct {
+	Name            string                `json:"name"`
+	SemanticVersion string                `json:"semanticVersion,omitempty"`
+	InformationURI  string                `json:"informationUri,omitempty"`
+	Rules           []ReportingDescriptor `json:"rules,omitempty"`
+}
+
+// ReportingDescriptor describes a rule or a notification.
+type ReportingDescriptor struct {
+	ID                   string                  `json:"id"`
+	Name                 string                  `json:"name,omitempty"`
+	ShortDescription     *MultiformatMessage     `json:"shortDescription,omitempty"`
+	FullDescription      *MultiformatMessage     `json:"fullDescription,omitempty"`
+	Help                 *MultiformatMessage     `json:"help,omitempty"`
+	HelpURI              string                  `json:"helpUri,omitempty"`
+	DefaultConfiguration *ReportingConfiguration `json:"defaultConfiguration,omitempty"`
+	Properties           PropertyBag             `json:"properties,omitempty"`
+}
+
+// ReportingDescriptorReference points to a ReportingDescriptor by id.
+type ReportingDescriptorReference struct {
+	ID string `json:"id"`
+}
+
+// ReportingConfiguration is the default configuration of a rule.
+type ReportingConfiguration struct {
+	Level string `json:"level,omitempty"`
+}
+
+// PropertyBag has extra properties. Semgrep stores the rule tags in "tags" and
+// the confidence in "precision".
+type PropertyBag map[string]interface{}
+
+// MultiformatMessage is a message in plain text and optionally Markdown.
+type MultiformatMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+// Message is a plain text message.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Invocation is how the tool was executed.
+type Invocation struct {
+	ExecutionSuccessful        bool           `json:"executionSuccessful"`
+	ToolExecutionNotifications []Notification `json:"toolExecutionNotifications,omitempty"`
+}
+
+// Notification is an error or a warning from the tool.
+type Notification struct {
+	Descriptor *ReportingDescriptorReference `json:"descriptor,omitempty"`
+	Level      string                        `json:"level,omitempty"`
+	Message    Message                       `json:"message"`
+	Locations  []Location                    `json:"locations,omitempty"`
+}
+
+// Result is a finding.
+type Result struct {
+	RuleID       string            `json:"ruleId,omitempty"`
+	Level        string            `json:"level,omitempty"`
+	Message      Message           `json:"message"`
+	Locations    []Location        `json:"locations,omitempty"`
+	Fingerprints map[string]string `json:"fingerprints,omitempty"`
+	CodeFlows    []CodeFlow        `json:"codeFlows,omitempty"`
+	Fixes        []Fix             `json:"fixes,omitempty"`
+	Suppressions []Suppression     `json:"suppressions,omitempty"`
+	Properties   PropertyBag       `json:"properties,omitempty"`
+}
+
+// Location is a location in a file.
+type Location struct {
+	PhysicalLocation *PhysicalLocation `json:"physicalLocation,omitempty"`
+	Message          *Message          `json:"message,omitempty"`
+}
+
+// PhysicalLocation is a region in a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is the path of a file.
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is a part of a file. Lines and columns start from 1.
+type Region struct {
+	StartLine   int              `json:"startLine,omitempty"`
+	StartColumn int              `json:"startColumn,omitempty"`
+	EndLine     int              `json:"endLine,omitempty"`
+	EndColumn   int              `json:"endColumn,omitempty"`
+	Snippet     *ArtifactContent `json:"snippet,omitempty"`
+}
+
+// ArtifactContent is the content of a file or a part of it.
+type ArtifactContent struct {
+	Text string `json:"text"`
+}
+
+// CodeFlow is the path of data through the code, e.g., from a taint source to
+// a sink.
+type CodeFlow struct {
+	Message     *Message     `json:"message,omitempty"`
+	ThreadFlows []ThreadFlow `json:"threadFlows"`
+}
+
+// ThreadFlow is a sequence of locations in a CodeFlow.
+type ThreadFlow struct {
+	Locations []ThreadFlowLocation `json:"locations"`
+}
+
+// ThreadFlowLocation is a location in a ThreadFlow.
+type ThreadFlowLocation struct {
+	Location     *Location `json:"location,omitempty"`
+	NestingLevel int       `json:"nestingLevel,omitempty"`
+}
+
+// Fix is a proposed fix for a result.
+type Fix struct {
+	Description     *Message         `json:"description,omitempty"`
+	ArtifactChanges []ArtifactChange `json:"artifactChanges"`
+}
+
+// ArtifactChange is the changes to a file in a Fix.
+type ArtifactChange struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Replacements     []Replacement    `json:"replacements"`
+}
+
+// Replacement replaces a region of a file.
+type Replacement struct {
+	DeletedRegion   Region           `json:"deletedRegion"`
+	InsertedContent *ArtifactContent `json:"insertedContent,omitempty"`
+}
+
+// Suppression is why a result was suppressed. Semgrep uses "inSource" for
+// `nosemgrep` comments.
+type Suppression struct {
+	Kind string `json:"kind"`
+}
@@ -0,0 +1,473 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/output/enum"
+	"github.com/parsiya/semgrep_go/output/sarif"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// The official SARIF 2.1.0 schema (OASIS errata 01).
+const sarifSchemaFile = "testdata/sarif-schema-2.1.0.json"
+
+var (
+	sarifSchemaOnce sync.Once
+	sarifSchema     *jsonschema.Schema
+	sarifSchemaErr  error
+)
+
+// checkSARIF validates the JSON against the SARIF 2.1.0 schema.
+func checkSARIF(data []byte) error {
+	sarifSchemaOnce.Do(func() {
+		sarifSchema, sarifSchemaErr = jsonschema.Compile(sarifSchemaFile)
+	})
+	if sarifSchemaErr != nil {
+		return fmt.Errorf("couldn't compile the SARIF schema: %w", sarifSchemaErr)
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return sarifSchema.Validate(v)
+}
+
+func validateSARIF(t *testing.T, data []byte) {
+	t.Helper()
+	if err := checkSARIF(data); err != nil {
+		t.Errorf("invalid SARIF: %#v", err)
+	}
+}
+
+func TestCheckSARIF(t *testing.T) {
+	// Make sure the schema catches problems.
+	err := checkSARIF([]byte(`{"version": "2.1.0", "runs": [{"tool": {"driver": {"name": "x"}},
+		"results": [{"message": {"text": "x"}, "level": "fatal"}]}]}`))
+	if err == nil {
+		t.Errorf("checkSARIF accepted an invalid level")
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("checkSARIF() error = %v, want a validation error", err)
+	}
+}
+
+func TestOutput_ToSARIF(t *testing.T) {
+	out, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := out.ToSARIF()
+	data, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateSARIF(t, data)
+
+	run := log.Runs[0]
+	if got := run.Tool.Driver.SemanticVersion; got != "1.42.0" {
+		t.Errorf("got version %q, want 1.42.0", got)
+	}
+	if len(run.Results) != 67 {
+		t.Errorf("got %d results, want 67", len(run.Results))
+	}
+	if len(run.Tool.Driver.Rules) != 28 {
+		t.Errorf("got %d rules, want 28", len(run.Tool.Driver.Rules))
+	}
+	if n := len(run.Invocations[0].ToolExecutionNotifications); n != 29 {
+		t.Errorf("got %d notifications, want 29", n)
+	}
+	flows := 0
+	for i, r := range run.Results {
+		if r.Fingerprints[sarifFingerprint] != out.Results[i].Extra.Fingerprint {
+			t.Errorf("result %d has the wrong fingerprint", i)
+		}
+		if len(r.CodeFlows) > 0 {
+			flows++
+		}
+	}
+	if flows != 37 {
+		t.Errorf("got %d results with code flows, want 37", flows)
+	}
+
+	// contrib.nodejsscan.crypto_node.node_md5 has the cwe, owasp, and
+	// confidence metadata.
+	for _, rule := range run.Tool.Driver.Rules {
+		if rule.ID != "contrib.nodejsscan.crypto_node.node_md5" {
+			continue
+		}
+		wantTags := []string{
+			"CWE-327: Use of a Broken or Risky Cryptographic Algorithm",
+			"OWASP-A03:2017 - Sensitive Data Exposure",
+			"OWASP-A02:2021 - Cryptographic Failures",
+			"LOW CONFIDENCE",
+			"security",
+		}
+		if got := fmt.Sprint(rule.Properties["tags"]); got != fmt.Sprint(wantTags) {
+			t.Errorf("got tags %s, want %v", got, wantTags)
+		}
+		if rule.Properties["precision"] != "low" {
+			t.Errorf("got precision %v, want low", rule.Properties["precision"])
+		}
+		if rule.DefaultConfiguration.Level != sarif.LevelWarning {
+			t.Errorf("got level %q, want warning", rule.DefaultConfiguration.Level)
+		}
+	}
+}
+
+func TestOutput_ToSARIF_FixAndCall(t *testing.T) {
+	data := []byte(`{"errors": [], "paths": {"scanned": ["app.py"]}, "results": [{
+		"check_id": "python.tainted-sql",
+		"path": "app.py",
+		"start": {"line": 10, "col": 5}, "end": {"line": 10, "col": 30},
+		"extra": {
+			"fingerprint": "requires login", "lines": "requires login",
+			"message": "Tainted SQL", "metadata": {}, "severity": "ERROR",
+			"fix": "cursor.execute(query, (user,))",
+			"is_ignored": true,
+			"dataflow_trace": {
+				"taint_source": ["CliCall", [
+					[{"path": "app.py", "start": {"line": 3, "col": 12}, "end": {"line": 3, "col": 20}}, "source()"],
+					[],
+					["CliLoc", [{"path": "lib.py", "start": {"line": 1, "col": 1}, "end": {"line": 1, "col": 5}}, "input"]]
+				]],
+				"intermediate_vars": [
+					{"content": "user", "location": {"path": "app.py", "start": {"line": 3, "col": 5}, "end": {"line": 3, "col": 9}}}
+				],
+				"taint_sink": ["CliLoc", [{"path": "app.py", "start": {"line": 10, "col": 5}, "end": {"line": 10, "col": 30}}, "cursor.execute(query)"]]
+			}
+		}
+	}]}`)
+	out, err := Deserialize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := out.ToSARIF()
+	js, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateSARIF(t, js)
+
+	res := log.Runs[0].Results[0]
+	if res.Level != sarif.LevelError {
+		t.Errorf("got level %q, want error", res.Level)
+	}
+	if res.Fingerprints != nil {
+		t.Errorf("got fingerprints %v, want none", res.Fingerprints)
+	}
+	if res.Locations[0].PhysicalLocation.Region.Snippet != nil {
+		t.Errorf("got a snippet for `requires login`")
+	}
+	if len(res.Suppressions) != 1 || res.Suppressions[0].Kind != "inSource" {
+		t.Errorf("got suppressions %v, want inSource", res.Suppressions)
+	}
+	if len(res.Fixes) != 1 || res.Fixes[0].ArtifactChanges[0].Replacements[0].InsertedContent.Text != "cursor.execute(query, (user,))" {
+		t.Errorf("got fixes %+v", res.Fixes)
+	}
+
+	locs := res.CodeFlows[0].ThreadFlows[0].Locations
+	want := []string{
+		"Source: 'source()' @ 'app.py:3'",
+		"Propagator: 'user' @ 'app.py:3'",
+		"Sink: 'cursor.execute(query)' @ 'app.py:10'",
+	}
+	if len(locs) != len(want) {
+		t.Fatalf("got %d code flow locations, want %d", len(locs), len(want))
+	}
+	for i, w := range want {
+		if got := locs[i].Location.Message.Text; got != w {
+			t.Errorf("location %d: got %q, want %q", i, got, w)
+		}
+	}
+	if locs[2].NestingLevel != 1 {
+		t.Errorf("got sink nesting level %d, want 1", locs[2].NestingLevel)
+	}
+}
+
+func TestOutput_ToSARIF_Paths(t *testing.T) {
+	tests := []struct {
+		path   string
+		uri    string
+		baseID string
+	}{
+		{"src/app.js", "src/app.js", sarif.SrcRoot},
+		{"dir/100%.go", "dir/100%25.go", sarif.SrcRoot},
+		{"dir/a#b.go", "dir/a%23b.go", sarif.SrcRoot},
+		{"dir/what?.go", "dir/what%3F.go", sarif.SrcRoot},
+		{"my app/main.go", "my%20app/main.go", sarif.SrcRoot},
+		{"a:b/x.go", "a%3Ab/x.go", sarif.SrcRoot},
+		{"/home/user/src/x.go", "file:///home/user/src/x.go", ""},
+		{"C:/my src/x.go", "file:///C:/my%20src/x.go", ""},
+	}
+	for _, tt := range tests {
+		out := Output{Results: []CliMatch{{
+			CheckId: "a",
+			Path:    Fpath(tt.path),
+			Start:   Position{Line: 1, Col: 1},
+			End:     Position{Line: 1, Col: 2},
+		}}}
+		data, err := json.Marshal(out.ToSARIF())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkSARIF(data); err != nil {
+			t.Errorf("path %q: invalid SARIF: %v", tt.path, err)
+		}
+		got := out.ToSARIF().Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation
+		if got.URI != tt.uri || got.URIBaseID != tt.baseID {
+			t.Errorf("path %q: got %q %q, want %q %q", tt.path, got.URI, got.URIBaseID, tt.uri, tt.baseID)
+		}
+	}
+
+	// The schema rejects a path that is not escaped.
+	bad := []byte(`{"version": "2.1.0", "runs": [{"tool": {"driver": {"name": "x"}},
+		"results": [{"message": {"text": "x"}, "locations": [{"physicalLocation":
+		{"artifactLocation": {"uri": "dir/100%.go"}}}]}]}]}`)
+	if checkSARIF(bad) == nil {
+		t.Errorf("checkSARIF accepted an invalid URI")
+	}
+}
+
+func TestOutput_ToSARIF_ZeroSpan(t *testing.T) {
+	msg := "Syntax error"
+	out := Output{Errors: []CliError{{
+		Level:   "warn",
+		Type:    "Syntax error",
+		Message: &msg,
+		Spans:   []ErrorSpan{{File: "main.go"}},
+	}}}
+	log := out.ToSARIF()
+	data, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateSARIF(t, data)
+
+	n := log.Runs[0].Invocations[0].ToolExecutionNotifications[0]
+	if len(n.Locations) != 1 || n.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("got notification locations %+v, want one without a region", n.Locations)
+	}
+}
+
+func TestFromSARIF_RoundTrip(t *testing.T) {
+	want, err := Deserialize(juiceShopJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(want.ToSARIF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateSARIF(t, data)
+	got, err := FromSARIF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The converted output can be exported to SARIF again.
+	again, err := json.Marshal(got.ToSARIF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateSARIF(t, again)
+
+	if got.Version == nil || *got.Version != "1.42.0" {
+		t.Errorf("got version %v, want 1.42.0", got.Version)
+	}
+	if len(got.Results) != len(want.Results) {
+		t.Fatalf("got %d results, want %d", len(got.Results), len(want.Results))
+	}
+	for i, g := range got.Results {
+		w := want.Results[i]
+		if g.RuleID() != w.RuleID() || g.FilePath() != w.FilePath() || g.Message() != w.Message() {
+			t.Errorf("result %d: got %s %s, want %s %s", i, g.RuleID(), g.FilePath(), w.RuleID(), w.FilePath())
+		}
+		if g.Start.Line != w.Start.Line || g.Start.Col != w.Start.Col || g.End.Line != w.End.Line || g.End.Col != w.End.Col {
+			t.Errorf("result %d: got positions %v-%v, want %v-%v", i, g.Start, g.End, w.Start, w.End)
+		}
+		if g.Severity() != w.Severity() {
+			t.Errorf("result %d: got severity %s, want %s", i, g.Severity(), w.Severity())
+		}
+		if g.Extra.Fingerprint != w.Extra.Fingerprint || g.Extra.Lines != w.Extra.Lines {
+			t.Errorf("result %d: fingerprint or lines are different", i)
+		}
+		for _, field := range []string{"cwe", "owasp", "confidence", "category", "references", "source"} {
+			if gv, wv := metadataValues(g, field), metadataValues(w, field); !reflect.DeepEqual(gv, wv) {
+				t.Errorf("result %d: got %s %v, want %v", i, field, gv, wv)
+			}
+		}
+	}
+	if len(got.Errors) != 29 {
+		t.Errorf("got %d errors, want 29", len(got.Errors))
+	}
+	for i, e := range got.Errors {
+		if e.ErrorLevel() != want.Errors[i].ErrorLevel() || e.ErrorType().Kind != want.Errors[i].ErrorType().Kind {
+			t.Errorf("error %d: got %s %s", i, e.ErrorLevel(), e.ErrorType())
+		}
+	}
+	// The output can be serialized and deserialized again.
+	js, err := got.Serialize(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Deserialize(js); err != nil {
+		t.Errorf("couldn't deserialize the converted output: %v", err)
+	}
+	// 37 files have results.
+	if len(got.Paths.Scanned) != 37 {
+		t.Errorf("got %d scanned paths, want 37", len(got.Paths.Scanned))
+	}
+}
+
+func TestFromSARIF(t *testing.T) {
+	// Similar to `semgrep --sarif`.
+	data := `{
+		"$schema": "https://docs.oasis-open.org/sarif/sarif/v2.1.0/os/schemas/sarif-schema-2.1.0.json",
+		"version": "2.1.0",
+		"runs": [{
+			"tool": {"driver": {"name": "Semgrep OSS", "semanticVersion": "1.52.0", "rules": [{
+				"id": "go.lang.security.audit.dangerous-exec-command",
+				"name": "go.lang.security.audit.dangerous-exec-command",
+				"defaultConfiguration": {"level": "error"},
+				"helpUri": "https://semgrep.dev/r/go.lang.security.audit.dangerous-exec-command",
+				"help": {"text": "Command injection", "markdown": "Command injection\n\n<b>References:</b>\n - [Semgrep Rule](https://semgrep.dev/r/go.lang.security.audit.dangerous-exec-command)\n"},
+				"properties": {"precision": "very-high", "tags": ["CWE-78: OS Command Injection", "OWASP-A01:2017 - Injection", "MEDIUM CONFIDENCE", "security"]}
+			}]}},
+			"invocations": [{"executionSuccessful": true, "toolExecutionNotifications": [{
+				"descriptor": {"id": "Syntax error"},
+				"level": "warning",
+				"message": {"text": "Syntax error at line main.go:3"},
+				"locations": [{"physicalLocation": {"artifactLocation": {"uri": "main.go", "uriBaseId": "%SRCROOT%"}, "region": {"startLine": 3, "startColumn": 1, "endLine": 3, "endColumn": 4}}}]
+			}]}],
+			"results": [{
+				"ruleId": "go.lang.security.audit.dangerous-exec-command",
+				"message": {"text": "Detected non-static command inside Command."},
+				"locations": [{"physicalLocation": {"artifactLocation": {"uri": "cmd/my%20app/main.go", "uriBaseId": "%SRCROOT%"}, "region": {"startLine": 12, "startColumn": 2, "endLine": 12, "endColumn": 30, "snippet": {"text": "exec.Command(userInput)"}}}}],
+				"fingerprints": {"matchBasedId/v1": "abc_0"},
+				"suppressions": [{"kind": "inSource"}],
+				"properties": {}
+			}]
+		}]
+	}`
+	validateSARIF(t, []byte(data))
+	out, err := FromSARIF(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := json.Marshal(out.ToSARIF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateSARIF(t, again)
+	if len(out.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(out.Results))
+	}
+	r := out.Results[0]
+	if r.FilePath() != "cmd/my app/main.go" {
+		t.Errorf("got path %q", r.FilePath())
+	}
+	if r.Severity() != enum.SeverityError {
+		t.Errorf("got severity %s, want ERROR from the rule", r.Severity())
+	}
+	if r.Extra.Lines != "exec.Command(userInput)" || r.Extra.Fingerprint != "abc_0" {
+		t.Errorf("got lines %q and fingerprint %q", r.Extra.Lines, r.Extra.Fingerprint)
+	}
+	if r.Extra.IsIgnored == nil || !*r.Extra.IsIgnored {
+		t.Errorf("result is not ignored")
+	}
+	wantMeta := map[string]interface{}{
+		"cwe":        []interface{}{"CWE-78: OS Command Injection"},
+		"owasp":      []interface{}{"A01:2017 - Injection"},
+		"confidence": "MEDIUM",
+		"category":   "security",
+		"source":     "https://semgrep.dev/r/go.lang.security.audit.dangerous-exec-command",
+		"references": []interface{}{"https://semgrep.dev/r/go.lang.security.audit.dangerous-exec-command"},
+	}
+	if !reflect.DeepEqual(r.Extra.Metadata, wantMeta) {
+		t.Errorf("got metadata %v, want %v", r.Extra.Metadata, wantMeta)
+	}
+
+	if len(out.Errors) != 1 {
+		t.Fatalf("got %d errors, want 1", len(out.Errors))
+	}
+	e := out.Errors[0]
+	if e.ErrorLevel() != enum.LevelWarn || e.ErrorType().Kind != enum.SyntaxError {
+		t.Errorf("got error %s %s", e.ErrorLevel(), e.ErrorType())
+	}
+	if e.Path == nil || *e.Path != "main.go" || len(e.Spans) != 1 || e.Spans[0].Start.Line != 3 {
+		t.Errorf("got error location %v %v", e.Path, e.Spans)
+	}
+
+	if _, err := FromSARIF(strings.NewReader(`{"version": "2.0.0", "runs": []}`)); err == nil {
+		t.Errorf("FromSARIF didn't return an error for SARIF 2.0.0")
+	}
+}
+
+func TestFromSARIF_Paths(t *testing.T) {
+	paths := []string{
+		"dir/a#b.go",
+		"dir/100%.go",
+		"dir/what?.go",
+		"my app/main.go",
+		"a:b/x.go",
+		"/home/user/src/x.go",
+		"C:/my src/x.go",
+	}
+	for _, p := range paths {
+		out := Output{Results: []CliMatch{{
+			CheckId: "a",
+			Path:    Fpath(p),
+			Start:   Position{Line: 1, Col: 1},
+			End:     Position{Line: 1, Col: 2},
+		}}}
+		data, err := json.Marshal(out.ToSARIF())
+		if err != nil {
+			t.Fatal(err)
+		}
+		validateSARIF(t, data)
+		got, err := FromSARIF(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Results) != 1 {
+			t.Fatalf("got %d results, want 1", len(got.Results))
+		}
+		if fp := got.Results[0].FilePath(); fp != p {
+			t.Errorf("FromSARIF(ToSARIF()) path = %q, want %q", fp, p)
+		}
+	}
+}
+
+func Test_fromSARIFURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"src/app.js", "src/app.js"},
+		{"cmd/my%20app/main.go", "cmd/my app/main.go"},
+		{"file:///home/user/src/x.go", "/home/user/src/x.go"},
+		{"file:///C:/src/x.go", "C:/src/x.go"},
+		{"file://C:/src/x.go", "C:/src/x.go"},
+		{"/C:/src/x.go", "C:/src/x.go"},
+		{"C:/my%20src/x.go", "C:/my src/x.go"},
+		{"https://example.com/x.go", "https://example.com/x.go"},
+		{"dir/a#b.go", "dir/a#b.go"},
+		{"dir/a%23b.go", "dir/a#b.go"},
+		{"dir/100%.go", "dir/100%.go"},
+		{"dir/100%25.go", "dir/100%.go"},
+		{"a%3Ab/x.go", "a:b/x.go"},
+		{"file://server/share/x.go", "//server/share/x.go"},
+	}
+	for _, tt := range tests {
+		if got := fromSARIFURI(tt.uri); got != tt.want {
+			t.Errorf("fromSARIFURI(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
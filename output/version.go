@@ -0,0 +1,192 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemVer is a parsed Semgrep version.
+type SemVer struct {
+	Major int
+	Minor int
+	Patch int
+
+	// Pre-release, e.g., "rc1" in "1.52.0-rc1". Empty for releases.
+	Pre string
+}
+
+// ParseVersion parses a Semgrep version like "1.52.0". A leading "v", a
+// pre-release suffix ("-rc1"), and build metadata ("+abc") are supported. The
+// patch number is optional.
+func ParseVersion(s string) (SemVer, error) {
+	var v SemVer
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	// Remove the build metadata.
+	if i := strings.Index(str, "+"); i >= 0 {
+		str = str[:i]
+	}
+	// Store the pre-release.
+	if i := strings.Index(str, "-"); i >= 0 {
+		v.Pre = str[i+1:]
+		str = str[:i]
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, fmt.Errorf("invalid Semgrep version: %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid Semgrep version: %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// MustParseVersion is ParseVersion but panics on errors. Use it for constants.
+func MustParseVersion(s string) SemVer {
+	v, err := ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String returns the version as "major.minor.patch[-pre]".
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1 if v < w, 0 if v == w, and 1 if v > w. A pre-release is
+// lower than the release with the same number.
+func (v SemVer) Compare(w SemVer) int {
+	pairs := [][2]int{{v.Major, w.Major}, {v.Minor, w.Minor}, {v.Patch, w.Patch}}
+	for _, p := range pairs {
+		if p[0] < p[1] {
+			return -1
+		}
+		if p[0] > p[1] {
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == w.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case w.Pre == "":
+		return -1
+	case v.Pre < w.Pre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// Less returns true if v < w.
+func (v SemVer) Less(w SemVer) bool {
+	return v.Compare(w) < 0
+}
+
+// Parse parses the version in the output.
+func (v Version) Parse() (SemVer, error) {
+	return ParseVersion(string(v))
+}
+
+// ----------
+
+// VersionStatus is the result of checking a Semgrep version against
+// Compatibility.
+type VersionStatus int
+
+const (
+	// The structs were tested with this version.
+	VersionTested VersionStatus = iota
+	// The version is between the oldest and newest tested versions.
+	VersionInRange
+	// The version is older than the oldest tested version.
+	VersionTooOld
+	// The version is newer than the newest tested version.
+	VersionTooNew
+)
+
+// String returns the status as a string.
+func (s VersionStatus) String() string {
+	switch s {
+	case VersionTested:
+		return "tested"
+	case VersionInRange:
+		return "in range"
+	case VersionTooOld:
+		return "too old"
+	case VersionTooNew:
+		return "too new"
+	default:
+		return "unknown"
+	}
+}
+
+// Compatible returns true if the version is inside the tested range.
+func (s VersionStatus) Compatible() bool {
+	return s == VersionTested || s == VersionInRange
+}
+
+// CompatibilityTable lists the Semgrep versions the output structs were
+// validated against.
+type CompatibilityTable struct {
+	// The semgrep-interfaces version used to generate the structs.
+	Schema SemVer
+
+	// Versions that were tested. Must be sorted.
+	Tested []SemVer
+}
+
+// Compatibility is the table for the bundled output structs. Keep it in sync
+// with the list in the README. Somewhere around v1.45.0 the type of
+// `CliError.Type` was changed from string to `ErrorType`, so older versions are
+// too old even if some of their outputs decode.
+var Compatibility = CompatibilityTable{
+	Schema: MustParseVersion("1.48.0"),
+	Tested: []SemVer{
+		MustParseVersion("1.48.0"),
+		MustParseVersion("1.49.0"),
+		MustParseVersion("1.50.0"),
+		MustParseVersion("1.52.0"),
+	},
+}
+
+// Min returns the oldest tested version.
+func (c CompatibilityTable) Min() SemVer {
+	return c.Tested[0]
+}
+
+// Max returns the newest tested version.
+func (c CompatibilityTable) Max() SemVer {
+	return c.Tested[len(c.Tested)-1]
+}
+
+// Check returns the status of a Semgrep version.
+func (c CompatibilityTable) Check(v SemVer) VersionStatus {
+	for _, t := range c.Tested {
+		if v.Compare(t) == 0 {
+			return VersionTested
+		}
+	}
+	switch {
+	case v.Less(c.Min()):
+		return VersionTooOld
+	case c.Max().Less(v):
+		return VersionTooNew
+	default:
+		return VersionInRange
+	}
+}
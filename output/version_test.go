@@ -0,0 +1,109 @@
+package output
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SemVer
+		wantErr bool
+	}{
+		{in: "1.52.0", want: SemVer{1, 52, 0, ""}},
+		{in: "v1.42.1\n", want: SemVer{1, 42, 1, ""}},
+		{in: "1.50", want: SemVer{1, 50, 0, ""}},
+		{in: "1.53.0-rc1+abc", want: SemVer{1, 53, 0, "rc1"}},
+		{in: "semgrep", wantErr: true},
+		{in: "1.x.0", wantErr: true},
+		{in: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseVersion(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSemVer_Compare(t *testing.T) {
+	tests := []struct {
+		v, w string
+		want int
+	}{
+		{"1.52.0", "1.52.0", 0},
+		{"1.9.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.52.0-rc1", "1.52.0", -1},
+		{"1.52.0", "1.52.0-rc1", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v+" "+tt.w, func(t *testing.T) {
+			if got := MustParseVersion(tt.v).Compare(MustParseVersion(tt.w)); got != tt.want {
+				t.Errorf("SemVer.Compare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompatibilityTable_Check(t *testing.T) {
+	tests := []struct {
+		version string
+		want    VersionStatus
+	}{
+		{"1.48.0", VersionTested},
+		{"1.51.0", VersionInRange},
+		{"1.30.0", VersionTooOld},
+		{"1.42.0", VersionTooOld},
+		{"1.45.0", VersionTooOld},
+		{"1.60.0", VersionTooNew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if got := Compatibility.Check(MustParseVersion(tt.version)); got != tt.want {
+				t.Errorf("Compatibility.Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeserializeLenient(t *testing.T) {
+	// The second result is missing the required `path` and the error has the
+	// wrong type for `code`.
+	data := []byte(`{
+		"version": "1.99.0",
+		"results": [
+			{"check_id": "a", "path": "a.go", "start": {"line": 1, "col": 1}, "end": {"line": 1, "col": 2}, "extra": {"message": "m", "lines": "l", "fingerprint": "f", "severity": "INFO", "metadata": {}}},
+			{"check_id": "b", "start": {"line": 1, "col": 1}, "end": {"line": 1, "col": 2}, "extra": {}}
+		],
+		"errors": [{"code": "three", "level": "warn", "type": "Syntax error"}],
+		"paths": {"scanned": ["a.go"], "skipped": [{"path": "b.go", "reason": "too_big"}]}
+	}`)
+
+	// The strict deserializer fails.
+	if _, err := Deserialize(data); err == nil {
+		t.Fatalf("Deserialize() error = nil, want an error")
+	}
+
+	out, skipped, err := DeserializeLenient(data)
+	if err != nil {
+		t.Fatalf("DeserializeLenient() error = %v", err)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("DeserializeLenient() skipped = %v, want 2 errors", skipped)
+	}
+	if len(out.Results) != 1 || out.Results[0].RuleID() != "a" {
+		t.Errorf("DeserializeLenient() results = %v", out.Results)
+	}
+	if len(out.Paths.Scanned) != 1 || len(out.Paths.Skipped) != 1 {
+		t.Errorf("DeserializeLenient() paths = %v", out.Paths)
+	}
+	if out.Version == nil || *out.Version != "1.99.0" {
+		t.Errorf("DeserializeLenient() version = %v", out.Version)
+	}
+}
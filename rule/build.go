@@ -0,0 +1,294 @@
+package rule
+
+import "fmt"
+
+// Clause constructors. They can be nested to build a pattern tree:
+//
+//	rule.Patterns(
+//		rule.Pattern("exec.Command($CMD, ...)"),
+//		rule.Not(`exec.Command("...", ...)`),
+//		rule.Inside("func $F(...) { ... }"),
+//	)
+
+// Pattern returns a `pattern` clause.
+func Pattern(p string) Clause {
+	return Clause{Pattern: p}
+}
+
+// Not returns a `pattern-not` clause.
+func Not(p string) Clause {
+	return Clause{PatternNot: p}
+}
+
+// Inside returns a `pattern-inside` clause.
+func Inside(p string) Clause {
+	return Clause{PatternInside: p}
+}
+
+// NotInside returns a `pattern-not-inside` clause.
+func NotInside(p string) Clause {
+	return Clause{PatternNotInside: p}
+}
+
+// Regex returns a `pattern-regex` clause.
+func Regex(re string) Clause {
+	return Clause{PatternRegex: re}
+}
+
+// NotRegex returns a `pattern-not-regex` clause.
+func NotRegex(re string) Clause {
+	return Clause{PatternNotRegex: re}
+}
+
+// Patterns returns a `patterns` clause. Code must match all the clauses.
+func Patterns(clauses ...Clause) Clause {
+	return Clause{Patterns: nonNil(clauses)}
+}
+
+// Either returns a `pattern-either` clause. Code must match any of the
+// clauses.
+func Either(clauses ...Clause) Clause {
+	return Clause{PatternEither: nonNil(clauses)}
+}
+
+// Focus returns a `focus-metavariable` clause.
+func Focus(metavariables ...string) Clause {
+	return Clause{FocusMetavariable: StringList(nonNil(metavariables))}
+}
+
+// MetavarRegex returns a `metavariable-regex` clause.
+func MetavarRegex(metavariable, regex string) Clause {
+	return Clause{MetavariableRegex: &MetavariableRegex{
+		Metavariable: metavariable,
+		Regex:        regex,
+	}}
+}
+
+// MetavarPattern returns a `metavariable-pattern` clause. language is
+// optional, if it's empty, the rule's language is used.
+func MetavarPattern(metavariable, language string, clause Clause) Clause {
+	return Clause{MetavariablePattern: &MetavariablePattern{
+		Metavariable: metavariable,
+		Language:     language,
+		Clause:       clause,
+	}}
+}
+
+// MetavarComparison returns a `metavariable-comparison` clause.
+func MetavarComparison(metavariable, comparison string) Clause {
+	return Clause{MetavariableComparison: &MetavariableComparison{
+		Metavariable: metavariable,
+		Comparison:   comparison,
+	}}
+}
+
+// MetavarAnalysis returns a `metavariable-analysis` clause. analyzer is
+// `redos` or `entropy`.
+func MetavarAnalysis(metavariable, analyzer string) Clause {
+	return Clause{MetavariableAnalysis: &MetavariableAnalysis{
+		Metavariable: metavariable,
+		Analyzer:     analyzer,
+	}}
+}
+
+// MetavarType returns a `metavariable-type` clause. One type is set in `type`,
+// more than one in `types`.
+func MetavarType(metavariable string, types ...string) Clause {
+	mt := &MetavariableType{Metavariable: metavariable}
+	if len(types) == 1 {
+		mt.Type = types[0]
+	} else {
+		mt.Types = types
+	}
+	return Clause{MetavariableType: mt}
+}
+
+// Propagator returns a taint propagator that passes taint from one
+// metavariable in the pattern to another.
+func Propagator(pattern, from, to string) Clause {
+	return Clause{Pattern: pattern, From: from, To: to}
+}
+
+// WithLabel returns a copy of the taint source with the label.
+func (cl Clause) WithLabel(label string) Clause {
+	cl.Label = label
+	return cl
+}
+
+// WithRequires returns a copy of the taint clause with the `requires`
+// expression.
+func (cl Clause) WithRequires(expr string) Clause {
+	cl.Requires = expr
+	return cl
+}
+
+// nonNil returns an empty slice instead of nil so an empty list is not
+// mistaken for an unset operator.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// ----------
+
+// Builder creates a rule. The methods can be chained:
+//
+//	r, err := rule.New("exec-command").
+//		Message("Command injection with $CMD").
+//		Severity(rule.SeverityError).
+//		Languages("go").
+//		Match(rule.Patterns(...)).
+//		Build()
+type Builder struct {
+	rule Rule
+	// Operators passed to Match that are only allowed inside `patterns`.
+	nested []string
+}
+
+// New returns a Builder for a rule with the id.
+func New(id string) *Builder {
+	return &Builder{rule: Rule{ID: id}}
+}
+
+// Message sets the message.
+func (b *Builder) Message(msg string) *Builder {
+	b.rule.Message = msg
+	return b
+}
+
+// Severity sets the severity.
+func (b *Builder) Severity(s Severity) *Builder {
+	b.rule.Severity = s
+	return b
+}
+
+// Languages adds languages.
+func (b *Builder) Languages(langs ...string) *Builder {
+	b.rule.Languages = append(b.rule.Languages, langs...)
+	return b
+}
+
+// Mode sets the mode.
+func (b *Builder) Mode(m Mode) *Builder {
+	b.rule.Mode = m
+	return b
+}
+
+// Metadata sets a metadata key.
+func (b *Builder) Metadata(key string, value interface{}) *Builder {
+	if b.rule.Metadata == nil {
+		b.rule.Metadata = map[string]interface{}{}
+	}
+	b.rule.Metadata[key] = value
+	return b
+}
+
+// Option sets a rule option. E.g., `Option("symbolic_propagation", true)`.
+func (b *Builder) Option(key string, value interface{}) *Builder {
+	if b.rule.Options == nil {
+		b.rule.Options = map[string]interface{}{}
+	}
+	b.rule.Options[key] = value
+	return b
+}
+
+// Match sets the top-level operator of a search mode rule. Only `pattern`,
+// `patterns`, `pattern-either`, and `pattern-regex` are allowed at the top
+// level. Other operators like `pattern-not` are not added to the rule and
+// Build returns an error.
+func (b *Builder) Match(cl Clause) *Builder {
+	r := &b.rule
+	r.Pattern, r.Patterns, r.PatternEither, r.PatternRegex =
+		cl.Pattern, cl.Patterns, cl.PatternEither, cl.PatternRegex
+
+	cl.Pattern, cl.Patterns, cl.PatternEither, cl.PatternRegex = "", nil, nil, ""
+	b.nested = cl.operators()
+	return b
+}
+
+// Sources adds taint sources. It sets the mode to taint if it's not set.
+func (b *Builder) Sources(clauses ...Clause) *Builder {
+	b.taint()
+	b.rule.PatternSources = append(b.rule.PatternSources, clauses...)
+	return b
+}
+
+// Sinks adds taint sinks. It sets the mode to taint if it's not set.
+func (b *Builder) Sinks(clauses ...Clause) *Builder {
+	b.taint()
+	b.rule.PatternSinks = append(b.rule.PatternSinks, clauses...)
+	return b
+}
+
+// Sanitizers adds taint sanitizers. It sets the mode to taint if it's not set.
+func (b *Builder) Sanitizers(clauses ...Clause) *Builder {
+	b.taint()
+	b.rule.PatternSanitizers = append(b.rule.PatternSanitizers, clauses...)
+	return b
+}
+
+// Propagators adds taint propagators. It sets the mode to taint if it's not
+// set.
+func (b *Builder) Propagators(clauses ...Clause) *Builder {
+	b.taint()
+	b.rule.PatternPropagators = append(b.rule.PatternPropagators, clauses...)
+	return b
+}
+
+func (b *Builder) taint() {
+	if b.rule.Mode == "" {
+		b.rule.Mode = TaintMode
+	}
+}
+
+// Fix sets the autofix.
+func (b *Builder) Fix(fix string) *Builder {
+	b.rule.Fix = fix
+	return b
+}
+
+// FixRegex sets the regex autofix.
+func (b *Builder) FixRegex(regex, replacement string) *Builder {
+	b.rule.FixRegex = &FixRegex{Regex: regex, Replacement: replacement}
+	return b
+}
+
+// Include adds globs to `paths.include`.
+func (b *Builder) Include(globs ...string) *Builder {
+	b.paths().Include = append(b.paths().Include, globs...)
+	return b
+}
+
+// Exclude adds globs to `paths.exclude`.
+func (b *Builder) Exclude(globs ...string) *Builder {
+	b.paths().Exclude = append(b.paths().Exclude, globs...)
+	return b
+}
+
+func (b *Builder) paths() *Paths {
+	if b.rule.Paths == nil {
+		b.rule.Paths = &Paths{}
+	}
+	return b.rule.Paths
+}
+
+// Build returns the rule and the result of Rule.Check. Operators passed to
+// Match that are not allowed at the top level are also reported. The rule is
+// returned even if it has problems.
+func (b *Builder) Build() (Rule, error) {
+	err := b.rule.Check()
+	if len(b.nested) == 0 {
+		return b.rule, err
+	}
+	checkErr, ok := err.(*CheckError)
+	if !ok {
+		checkErr = &CheckError{RuleID: b.rule.ID}
+	}
+	for _, op := range b.nested {
+		checkErr.Problems = append(checkErr.Problems,
+			fmt.Sprintf("%s is not allowed at the top level, put it inside patterns", op))
+	}
+	return b.rule, checkErr
+}
@@ -0,0 +1,281 @@
+package rule
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	got, err := New("go.exec-command").
+		Message("Command injection with $CMD").
+		Severity(SeverityError).
+		Languages("go").
+		Metadata("cwe", []string{"CWE-78"}).
+		Match(Patterns(
+			Either(
+				Pattern("exec.Command($CMD, ...)"),
+				Pattern("exec.CommandContext($CTX, $CMD, ...)"),
+			),
+			Not(`exec.Command("...", ...)`),
+			Inside("func $FUNC(...) { ... }"),
+			MetavarRegex("$FUNC", "^handle"),
+			Focus("$CMD"),
+		)).
+		Include("*.go").
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	// The built rule must be the same as the parsed one.
+	want, err := Parse([]byte(`
+id: go.exec-command
+message: Command injection with $CMD
+severity: ERROR
+languages: [go]
+metadata:
+  cwe: [CWE-78]
+patterns:
+  - pattern-either:
+      - pattern: exec.Command($CMD, ...)
+      - pattern: exec.CommandContext($CTX, $CMD, ...)
+  - pattern-not: exec.Command("...", ...)
+  - pattern-inside: func $FUNC(...) { ... }
+  - metavariable-regex:
+      metavariable: $FUNC
+      regex: ^handle
+  - focus-metavariable: $CMD
+paths:
+  include: ["*.go"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Marshal([]Rule{got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Errorf("Build() =\n%s", data)
+	}
+
+	wantVars := []string{"$CMD", "$CTX", "$FUNC"}
+	if vars := got.Metavariables(); !reflect.DeepEqual(vars, wantVars) {
+		t.Errorf("Metavariables() = %v, want %v", vars, wantVars)
+	}
+}
+
+func TestBuilder_Taint(t *testing.T) {
+	got, err := New("taint").
+		Languages("python").
+		Sources(Pattern("request.$X").WithLabel("USER")).
+		Sinks(Patterns(
+			Pattern("cursor.execute($Q)"),
+			Focus("$Q"),
+		).WithRequires("USER")).
+		Sanitizers(Pattern("int(...)")).
+		Propagators(Propagator("$L.append($I)", "$I", "$L")).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if got.Mode != TaintMode || got.PatternSources[0].Label != "USER" || got.PatternSinks[0].Requires != "USER" {
+		t.Errorf("Build() = %+v", got)
+	}
+}
+
+func TestRule_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    []string
+	}{
+		{
+			name:    "valid pattern",
+			builder: New("a").Match(Pattern("foo()")),
+		},
+		{
+			name:    "missing id and operator",
+			builder: New(""),
+			want:    []string{"missing id", "search mode rule needs one of"},
+		},
+		{
+			name:    "top-level pattern-not",
+			builder: New("a").Match(Not("foo()")),
+			want:    []string{"search mode rule needs one of", "pattern-not is not allowed at the top level"},
+		},
+		{
+			name:    "taint fields in search mode",
+			builder: New("a").Mode(SearchMode).Match(Pattern("foo()")).Sinks(Pattern("bar()")),
+			want:    []string{"pattern-sinks is not allowed in search mode"},
+		},
+		{
+			name:    "search fields in taint mode",
+			builder: New("a").Sources(Pattern("foo()")).Sinks(Pattern("bar()")).Match(Pattern("baz()")),
+			want:    []string{"pattern is not allowed in taint mode"},
+		},
+		{
+			name:    "missing sinks",
+			builder: New("a").Sources(Pattern("foo()")),
+			want:    []string{"taint mode rule needs pattern-sinks"},
+		},
+		{
+			name: "unbound metavariables",
+			builder: New("a").Match(Patterns(
+				Pattern("foo($X)"),
+				Not("foo($Y)"),
+				MetavarRegex("$Y", "a"),
+				MetavarComparison("$X", "$X > $Z"),
+				Focus("$W"),
+			)),
+			want: []string{
+				"patterns[2]: references unbound metavariable $Y",
+				"patterns[3]: references unbound metavariable $Z",
+				"patterns[4]: references unbound metavariable $W",
+			},
+		},
+		{
+			name: "metavariables bound in outer scope and regex groups",
+			builder: New("a").Match(Patterns(
+				Inside("func $F(...) { ... }"),
+				Regex(`secret=(?P<KEY>\w+)`),
+				Patterns(Pattern("foo()"), MetavarRegex("$F", "a"), Focus("$KEY")),
+			)),
+		},
+		{
+			name: "metavariable-analysis and metavariable-type",
+			builder: New("a").Match(Patterns(
+				Pattern("re.compile($RE)"),
+				MetavarAnalysis("$RE", "redos"),
+				MetavarType("$RE", "str"),
+				MetavarType("$X", "int", "float"),
+			)),
+			want: []string{"patterns[3]: references unbound metavariable $X"},
+		},
+		{
+			name: "unknown operator",
+			builder: New("a").Match(Patterns(
+				Pattern("foo($X)"),
+				Clause{Extra: map[string]interface{}{"metavariable-foo": "bar"}},
+			)),
+		},
+		{
+			name: "invalid clauses",
+			builder: New("a").Match(Either(
+				Pattern("foo()"),
+				Not("bar()"),
+				Patterns(Not("baz()")),
+				Either(),
+				Clause{Pattern: "a", PatternInside: "b"},
+				Clause{},
+			)),
+			want: []string{
+				"pattern-either[1]: pattern-not is not allowed here",
+				"pattern-either[2].patterns: needs at least one positive operator",
+				"pattern-either[3].pattern-either: empty list",
+				"pattern-either[4]: clause has more than one operator: pattern, pattern-inside",
+				"pattern-either[5]: clause has no operator",
+			},
+		},
+		{
+			name:    "unbound propagator",
+			builder: New("a").Sources(Pattern("a")).Sinks(Pattern("b")).Propagators(Propagator("$L.append($I)", "$X", "")),
+			want:    []string{"from references unbound metavariable $X", "propagator needs to"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Build() error = %v, want nil", err)
+				}
+				return
+			}
+			var checkErr *CheckError
+			if !errors.As(err, &checkErr) {
+				t.Fatalf("Build() error = %v, want a *CheckError", err)
+			}
+			if len(checkErr.Problems) != len(tt.want) {
+				t.Fatalf("Build() problems = %q, want %q", checkErr.Problems, tt.want)
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(checkErr.Problems[i], w) {
+					t.Errorf("Build() problem %d = %q, want %q", i, checkErr.Problems[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilder_Match_Nested(t *testing.T) {
+	r, err := New("a").Match(Patterns(Pattern("foo()"))).Match(Not("bar()")).Build()
+	if err == nil || !strings.Contains(err.Error(), "pattern-not is not allowed at the top level") {
+		t.Errorf("Build() error = %v", err)
+	}
+	if r.Patterns != nil || len(r.Extra) != 0 {
+		t.Errorf("Build() = %+v, want no patterns and no extra keys", r)
+	}
+	// A later Match replaces the invalid operator.
+	if _, err := New("a").Match(Not("bar()")).Match(Pattern("foo()")).Build(); err != nil {
+		t.Errorf("Build() error = %v, want nil", err)
+	}
+}
+
+func TestRule_Check_Testdata(t *testing.T) {
+	for _, file := range []string{"dangerous-exec-command.yaml", "tainted-sql-string.yaml", "javascript-misc.yaml"} {
+		rules, err := ParseFile("testdata/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range rules {
+			if err := r.Check(); err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+		}
+	}
+}
+
+func TestRule_Check_Operators(t *testing.T) {
+	rules, err := Parse([]byte(`
+rules:
+  - id: redos
+    message: redos
+    languages: [python]
+    severity: WARNING
+    patterns:
+      - pattern: re.compile($RE)
+      - metavariable-analysis:
+          analyzer: redos
+          metavariable: $RE
+  - id: typed
+    message: typed
+    languages: [java]
+    severity: WARNING
+    patterns:
+      - pattern: $X == $Y
+      - metavariable-type:
+          metavariable: $X
+          types: [String, Integer]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rules {
+		if err := r.Check(); err != nil {
+			t.Errorf("Check() error = %v", err)
+		}
+	}
+	if ma := rules[0].Patterns[1].MetavariableAnalysis; ma == nil || ma.Analyzer != "redos" {
+		t.Errorf("metavariable-analysis = %+v", ma)
+	}
+	if mt := rules[1].Patterns[1].MetavariableType; mt == nil || len(mt.Types) != 2 {
+		t.Errorf("metavariable-type = %+v", mt)
+	}
+}
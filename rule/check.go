@@ -0,0 +1,388 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CheckError is returned by Rule.Check. It has all the problems in the rule.
+type CheckError struct {
+	RuleID   string
+	Problems []string
+}
+
+// Error implements the error interface.
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("rule %q: %s", e.RuleID, strings.Join(e.Problems, "; "))
+}
+
+// Operators that are only allowed inside `patterns`. If they are at the top
+// level of a rule, they end up in Rule.Extra.
+var nestedOnly = []string{
+	"pattern-not",
+	"pattern-inside",
+	"pattern-not-inside",
+	"pattern-not-regex",
+	"focus-metavariable",
+	"metavariable-regex",
+	"metavariable-pattern",
+	"metavariable-comparison",
+	"metavariable-analysis",
+	"metavariable-type",
+}
+
+// Check runs static checks on the rule without Semgrep. It returns a
+// *CheckError if:
+//   - The rule doesn't have an id.
+//   - A search mode rule doesn't have exactly one top-level pattern operator,
+//     has taint fields, or has an operator like `pattern-not` at the top level.
+//   - A taint mode rule doesn't have sources and sinks or has search fields.
+//   - A clause has zero or more than one operator, a `patterns` doesn't have
+//     a positive operator, or a `pattern-either` has a negative one.
+//   - A metavariable operator references a metavariable that is not bound by
+//     the positive patterns in its scope.
+//
+// Join and extract mode rules are not checked.
+func (r Rule) Check() error {
+	c := checker{}
+	if r.ID == "" {
+		c.addf("missing id")
+	}
+
+	switch r.Mode {
+	case "", SearchMode:
+		c.checkSearch(r)
+	case TaintMode:
+		c.checkTaint(r)
+	}
+
+	if len(c.problems) == 0 {
+		return nil
+	}
+	return &CheckError{RuleID: r.ID, Problems: c.problems}
+}
+
+// Metavariables returns the sorted metavariables bound by the rule's positive
+// patterns. For taint mode rules, it's the metavariables in the sources and
+// sinks.
+func (r Rule) Metavariables() []string {
+	bound := map[string]bool{}
+	switch r.Mode {
+	case TaintMode:
+		for _, list := range [][]Clause{r.PatternSources, r.PatternSinks} {
+			for _, cl := range list {
+				bind(bound, cl)
+			}
+		}
+	default:
+		bind(bound, r.top())
+	}
+	return sortedKeys(bound)
+}
+
+// top returns the top-level search mode operators as a clause.
+func (r Rule) top() Clause {
+	return Clause{
+		Pattern:       r.Pattern,
+		Patterns:      r.Patterns,
+		PatternEither: r.PatternEither,
+		PatternRegex:  r.PatternRegex,
+	}
+}
+
+// checker collects problems.
+type checker struct {
+	problems []string
+}
+
+func (c *checker) addf(format string, a ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(format, a...))
+}
+
+func (c *checker) checkSearch(r Rule) {
+	top := r.top()
+	switch n := len(top.operators()); {
+	case n == 0:
+		c.addf("search mode rule needs one of pattern, patterns, pattern-either, or pattern-regex")
+	case n > 1:
+		c.addf("search mode rule has more than one top-level operator: %s",
+			strings.Join(top.operators(), ", "))
+	}
+	for _, key := range nestedOnly {
+		if _, ok := r.Extra[key]; ok {
+			c.addf("%s is not allowed at the top level, put it inside patterns", key)
+		}
+	}
+	for _, f := range r.taintFields() {
+		c.addf("%s is not allowed in search mode, set mode: taint", f)
+	}
+	if r.Patterns != nil {
+		c.checkPatterns(r.Patterns, "patterns", map[string]bool{})
+	}
+	if r.PatternEither != nil {
+		c.checkEither(r.PatternEither, "pattern-either", map[string]bool{})
+	}
+}
+
+func (c *checker) checkTaint(r Rule) {
+	if len(r.PatternSources) == 0 {
+		c.addf("taint mode rule needs pattern-sources")
+	}
+	if len(r.PatternSinks) == 0 {
+		c.addf("taint mode rule needs pattern-sinks")
+	}
+	for _, op := range r.top().operators() {
+		c.addf("%s is not allowed in taint mode", op)
+	}
+
+	lists := []struct {
+		name    string
+		clauses []Clause
+	}{
+		{"pattern-sources", r.PatternSources},
+		{"pattern-sinks", r.PatternSinks},
+		{"pattern-sanitizers", r.PatternSanitizers},
+		{"pattern-propagators", r.PatternPropagators},
+	}
+	for _, list := range lists {
+		for i, cl := range list.clauses {
+			path := fmt.Sprintf("%s[%d]", list.name, i)
+			c.checkPositive(cl, path, map[string]bool{})
+			if list.name != "pattern-propagators" {
+				continue
+			}
+			bound := map[string]bool{}
+			bind(bound, cl)
+			for _, key := range []struct{ name, mv string }{{"from", cl.From}, {"to", cl.To}} {
+				switch {
+				case key.mv == "":
+					c.addf("%s: propagator needs %s", path, key.name)
+				case !bound[key.mv]:
+					c.addf("%s: %s references unbound metavariable %s", path, key.name, key.mv)
+				}
+			}
+		}
+	}
+}
+
+// taintFields returns the taint mode fields that are set.
+func (r Rule) taintFields() []string {
+	var fields []string
+	if r.PatternSources != nil {
+		fields = append(fields, "pattern-sources")
+	}
+	if r.PatternSinks != nil {
+		fields = append(fields, "pattern-sinks")
+	}
+	if r.PatternSanitizers != nil {
+		fields = append(fields, "pattern-sanitizers")
+	}
+	if r.PatternPropagators != nil {
+		fields = append(fields, "pattern-propagators")
+	}
+	return fields
+}
+
+// checkClause checks that the clause has exactly one operator and checks its
+// children. outer has the metavariables bound in the enclosing scopes.
+func (c *checker) checkClause(cl Clause, path string, outer map[string]bool) {
+	switch ops := cl.operators(); len(ops) {
+	case 0:
+		c.addf("%s: clause has no operator", path)
+	case 1:
+	default:
+		c.addf("%s: clause has more than one operator: %s", path, strings.Join(ops, ", "))
+	}
+	if cl.Patterns != nil {
+		c.checkPatterns(cl.Patterns, path+".patterns", outer)
+	}
+	if cl.PatternEither != nil {
+		c.checkEither(cl.PatternEither, path+".pattern-either", outer)
+	}
+	if mp := cl.MetavariablePattern; mp != nil {
+		c.checkClause(mp.Clause, path+".metavariable-pattern", outer)
+	}
+}
+
+// checkPositive checks a clause that must match something on its own, e.g.,
+// an item in `pattern-either` or a taint source.
+func (c *checker) checkPositive(cl Clause, path string, outer map[string]bool) {
+	c.checkClause(cl, path, outer)
+	for _, op := range cl.operators() {
+		if !isPositive(op) {
+			c.addf("%s: %s is not allowed here, put it inside patterns", path, op)
+		}
+	}
+}
+
+func (c *checker) checkEither(clauses []Clause, path string, outer map[string]bool) {
+	if len(clauses) == 0 {
+		c.addf("%s: empty list", path)
+	}
+	for i, cl := range clauses {
+		c.checkPositive(cl, fmt.Sprintf("%s[%d]", path, i), outer)
+	}
+}
+
+func (c *checker) checkPatterns(clauses []Clause, path string, outer map[string]bool) {
+	if len(clauses) == 0 {
+		c.addf("%s: empty list", path)
+		return
+	}
+
+	// Metavariables bound in this scope. The order of the clauses doesn't
+	// matter in Semgrep.
+	bound := map[string]bool{}
+	for k := range outer {
+		bound[k] = true
+	}
+	positive := false
+	for _, cl := range clauses {
+		for _, op := range cl.operators() {
+			positive = positive || isPositive(op)
+		}
+		bind(bound, cl)
+	}
+	if !positive {
+		c.addf("%s: needs at least one positive operator like pattern or pattern-inside", path)
+	}
+
+	for i, cl := range clauses {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		c.checkClause(cl, p, bound)
+		for _, mv := range cl.references() {
+			if !bound[mv] {
+				c.addf("%s: references unbound metavariable %s", p, mv)
+			}
+		}
+	}
+}
+
+// operators returns the names of the operators set in the clause.
+func (cl Clause) operators() []string {
+	var ops []string
+	add := func(set bool, name string) {
+		if set {
+			ops = append(ops, name)
+		}
+	}
+	add(cl.Pattern != "", "pattern")
+	add(cl.PatternNot != "", "pattern-not")
+	add(cl.PatternInside != "", "pattern-inside")
+	add(cl.PatternNotInside != "", "pattern-not-inside")
+	add(cl.PatternRegex != "", "pattern-regex")
+	add(cl.PatternNotRegex != "", "pattern-not-regex")
+	add(cl.Patterns != nil, "patterns")
+	add(cl.PatternEither != nil, "pattern-either")
+	add(cl.FocusMetavariable != nil, "focus-metavariable")
+	add(cl.MetavariableRegex != nil, "metavariable-regex")
+	add(cl.MetavariablePattern != nil, "metavariable-pattern")
+	add(cl.MetavariableComparison != nil, "metavariable-comparison")
+	add(cl.MetavariableAnalysis != nil, "metavariable-analysis")
+	add(cl.MetavariableType != nil, "metavariable-type")
+	// Operators that are not in Clause, e.g., from a newer Semgrep.
+	var unknown []string
+	for k := range cl.Extra {
+		if isOperatorKey(k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return append(ops, unknown...)
+}
+
+// isOperatorKey returns true if the key looks like a pattern or metavariable
+// operator.
+func isOperatorKey(key string) bool {
+	return strings.HasPrefix(key, "pattern") || strings.HasPrefix(key, "metavariable-")
+}
+
+// isPositive returns true if the operator matches code on its own.
+func isPositive(op string) bool {
+	switch op {
+	case "pattern", "pattern-inside", "pattern-regex", "patterns", "pattern-either":
+		return true
+	}
+	return false
+}
+
+// references returns the metavariables referenced by the metavariable
+// operators in the clause.
+func (cl Clause) references() []string {
+	var refs []string
+	refs = append(refs, cl.FocusMetavariable...)
+	if mr := cl.MetavariableRegex; mr != nil {
+		refs = append(refs, mr.Metavariable)
+	}
+	if mp := cl.MetavariablePattern; mp != nil {
+		refs = append(refs, mp.Metavariable)
+	}
+	if mc := cl.MetavariableComparison; mc != nil {
+		if mc.Metavariable != "" {
+			refs = append(refs, mc.Metavariable)
+		}
+		refs = append(refs, metavariableRegex.FindAllString(mc.Comparison, -1)...)
+	}
+	if ma := cl.MetavariableAnalysis; ma != nil {
+		refs = append(refs, ma.Metavariable)
+	}
+	if mt := cl.MetavariableType; mt != nil {
+		refs = append(refs, mt.Metavariable)
+	}
+	return refs
+}
+
+// FindMetavariables returns the metavariables in s in order, e.g., `$X`,
+// `$FOO_1`, and `$...ARGS`. Duplicates are not removed.
+func FindMetavariables(s string) []string {
+	return metavariableRegex.FindAllString(s, -1)
+}
+
+var (
+	// Matches `$X`, `$FOO_1`, and `$...ARGS`.
+	metavariableRegex = regexp.MustCompile(`\$(?:\.\.\.)?[A-Z_][A-Z0-9_]*`)
+	// Named capture groups in `pattern-regex` and `metavariable-regex` bind
+	// metavariables.
+	namedGroupRegex = regexp.MustCompile(`\(\?P?<([A-Za-z_][A-Za-z0-9_]*)>`)
+)
+
+// bind adds the metavariables bound by the positive operators in the clause.
+func bind(bound map[string]bool, cl Clause) {
+	for _, p := range []string{cl.Pattern, cl.PatternInside} {
+		for _, mv := range metavariableRegex.FindAllString(p, -1) {
+			bound[mv] = true
+		}
+	}
+	for _, re := range []string{cl.PatternRegex, regexOf(cl.MetavariableRegex)} {
+		for _, m := range namedGroupRegex.FindAllStringSubmatch(re, -1) {
+			bound["$"+m[1]] = true
+		}
+	}
+	for _, child := range cl.Patterns {
+		bind(bound, child)
+	}
+	for _, child := range cl.PatternEither {
+		bind(bound, child)
+	}
+	if mp := cl.MetavariablePattern; mp != nil {
+		bind(bound, mp.Clause)
+	}
+}
+
+func regexOf(mr *MetavariableRegex) string {
+	if mr == nil {
+		return ""
+	}
+	return mr.Regex
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,268 @@
+// Package lint checks Semgrep rule files against a metadata and style policy
+// without running Semgrep.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/parsiya/semgrep_go/rule"
+	"gopkg.in/yaml.v3"
+)
+
+// Check names in Diagnostic.Check.
+const (
+	CheckMetadata       = "metadata"        // A required metadata key is missing.
+	CheckMetadataFormat = "metadata-format" // A metadata value has the wrong format.
+	CheckID             = "id"              // The id doesn't match the naming convention.
+	CheckMessage        = "message"         // The message references an unbound metavariable.
+	CheckLanguage       = "language"        // The language is not allowed.
+	CheckDuplicateID    = "duplicate-id"    // Another rule has the same id.
+	CheckRule           = "rule"            // A problem reported by rule.Rule.Check.
+)
+
+// Diagnostic is a policy violation in a rule file.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	RuleID  string
+	Check   string
+	Message string
+}
+
+// String returns the diagnostic as `file:line:column: [check] rule-id: message`.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: [%s] %s: %s", d.File, d.Line, d.Column, d.Check, d.RuleID, d.Message)
+}
+
+// Policy is the set of checks. Empty fields are not checked.
+type Policy struct {
+	// Metadata keys that every rule must have.
+	RequiredMetadata []string
+	// The format of metadata values. If the value is a list, every item must
+	// match. Only string values are checked.
+	MetadataFormats map[string]*regexp.Regexp
+	// Rule ids must match this.
+	IDPattern *regexp.Regexp
+	// Allowed values in `languages`.
+	Languages []string
+	// Report metavariables in the message that are not bound by the patterns.
+	BoundMessageMetavariables bool
+	// Report the problems from rule.Rule.Check.
+	RuleCheck bool
+}
+
+// DefaultPolicy returns a policy that requires the metadata used by the
+// Semgrep registry and checks everything else except languages.
+func DefaultPolicy() Policy {
+	return Policy{
+		RequiredMetadata: []string{"cwe", "owasp", "confidence", "likelihood", "impact", "references"},
+		MetadataFormats: map[string]*regexp.Regexp{
+			"cwe":        regexp.MustCompile(`^CWE-\d+: .+`),
+			"owasp":      regexp.MustCompile(`^A\d{2}:\d{4} - .+`),
+			"confidence": regexp.MustCompile(`^(LOW|MEDIUM|HIGH)$`),
+			"likelihood": regexp.MustCompile(`^(LOW|MEDIUM|HIGH)$`),
+			"impact":     regexp.MustCompile(`^(LOW|MEDIUM|HIGH)$`),
+			"references": regexp.MustCompile(`^https?://`),
+		},
+		// E.g., `go.lang.security.audit.dangerous-exec-command`.
+		IDPattern:                 regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*(\.[a-z0-9][a-z0-9_-]*)*$`),
+		BoundMessageMetavariables: true,
+		RuleCheck:                 true,
+	}
+}
+
+// Lint checks the rule files. Directories are walked recursively for `.yaml`
+// and `.yml` files. Duplicate ids are reported across all the files. The
+// diagnostics are sorted by file and line. An error is returned if a file
+// cannot be read or is not valid YAML.
+func (p Policy) Lint(paths ...string) ([]Diagnostic, error) {
+	l := p.linter()
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			// Files passed directly are always linted.
+			if file != path && !isRuleFile(file) {
+				return nil
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			return l.lint(file, data)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l.sorted(), nil
+}
+
+// LintData checks the rules in data. file is only used in the diagnostics.
+func (p Policy) LintData(file string, data []byte) ([]Diagnostic, error) {
+	l := p.linter()
+	if err := l.lint(file, data); err != nil {
+		return nil, err
+	}
+	return l.sorted(), nil
+}
+
+func isRuleFile(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// linter keeps the state of one Lint call.
+type linter struct {
+	policy Policy
+	diags  []Diagnostic
+	// The first location of each rule id.
+	seen map[string]Diagnostic
+}
+
+func (p Policy) linter() *linter {
+	return &linter{policy: p, seen: map[string]Diagnostic{}}
+}
+
+func (l *linter) sorted() []Diagnostic {
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		a, b := l.diags[i], l.diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.diags
+}
+
+func (l *linter) lint(file string, data []byte) error {
+	nodes, err := rule.Nodes(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	for _, n := range nodes {
+		var r rule.Rule
+		if err := n.Decode(&r); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		l.rule(file, r, n)
+	}
+	return nil
+}
+
+// rule runs the checks on one rule. n is the rule's mapping node.
+func (l *linter) rule(file string, r rule.Rule, n *yaml.Node) {
+	report := func(at *yaml.Node, check, format string, a ...interface{}) {
+		if at == nil {
+			at = n
+		}
+		l.diags = append(l.diags, Diagnostic{
+			File:    file,
+			Line:    at.Line,
+			Column:  at.Column,
+			RuleID:  r.ID,
+			Check:   check,
+			Message: fmt.Sprintf(format, a...),
+		})
+	}
+	p := l.policy
+
+	_, idNode := rule.Lookup(n, "id")
+	if prev, ok := l.seen[r.ID]; ok {
+		report(idNode, CheckDuplicateID, "duplicate id, first defined at %s:%d", prev.File, prev.Line)
+	} else if idNode != nil {
+		l.seen[r.ID] = Diagnostic{File: file, Line: idNode.Line}
+	}
+	if p.IDPattern != nil && !p.IDPattern.MatchString(r.ID) {
+		report(idNode, CheckID, "id doesn't match %s", p.IDPattern)
+	}
+
+	metadataKey, metadata := rule.Lookup(n, "metadata")
+	for _, key := range p.RequiredMetadata {
+		if _, ok := r.Metadata[key]; !ok {
+			report(metadataKey, CheckMetadata, "missing metadata key %q", key)
+		}
+	}
+	if metadata != nil {
+		for _, key := range sortedKeys(p.MetadataFormats) {
+			re := p.MetadataFormats[key]
+			_, v := rule.Lookup(metadata, key)
+			if v == nil {
+				continue
+			}
+			items := []*yaml.Node{v}
+			if v.Kind == yaml.SequenceNode {
+				items = v.Content
+			}
+			for _, item := range items {
+				if item.Kind == yaml.ScalarNode && !re.MatchString(item.Value) {
+					report(item, CheckMetadataFormat, "metadata %q value %q doesn't match %s", key, item.Value, re)
+				}
+			}
+		}
+	}
+
+	if len(p.Languages) > 0 {
+		if _, langs := rule.Lookup(n, "languages"); langs != nil {
+			for _, lang := range langs.Content {
+				if !contains(p.Languages, lang.Value) {
+					report(lang, CheckLanguage, "language %q is not allowed", lang.Value)
+				}
+			}
+		}
+	}
+
+	if p.BoundMessageMetavariables && (r.Mode == "" || r.Mode == rule.SearchMode || r.Mode == rule.TaintMode) {
+		_, message := rule.Lookup(n, "message")
+		bound := r.Metavariables()
+		reported := map[string]bool{}
+		for _, mv := range rule.FindMetavariables(r.Message) {
+			if !contains(bound, mv) && !reported[mv] {
+				reported[mv] = true
+				report(message, CheckMessage, "message references unbound metavariable %s", mv)
+			}
+		}
+	}
+
+	if p.RuleCheck {
+		var checkErr *rule.CheckError
+		if err := r.Check(); errors.As(err, &checkErr) {
+			for _, problem := range checkErr.Problems {
+				report(nil, CheckRule, "%s", problem)
+			}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]*regexp.Regexp) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
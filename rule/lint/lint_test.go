@@ -0,0 +1,121 @@
+package lint
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestPolicy_Lint(t *testing.T) {
+	p := DefaultPolicy()
+	p.Languages = []string{"go", "python"}
+
+	got, err := p.Lint("testdata/bad.yaml")
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+
+	type diag struct {
+		Line  int
+		ID    string
+		Check string
+	}
+	want := []diag{
+		{2, "Bad_ID", CheckID},
+		{3, "Bad_ID", CheckMessage},
+		{5, "Bad_ID", CheckLanguage},
+		{8, "Bad_ID", CheckMetadataFormat},
+		{11, "Bad_ID", CheckMetadataFormat},
+		{12, "Bad_ID", CheckMetadataFormat},
+		{17, "go.missing-metadata", CheckMetadata},
+		{17, "go.missing-metadata", CheckMetadata},
+		{17, "go.missing-metadata", CheckMetadata},
+		{17, "go.missing-metadata", CheckMetadata},
+		{17, "go.missing-metadata", CheckMetadata},
+		{17, "go.missing-metadata", CheckMetadata},
+		{17, "go.missing-metadata", CheckRule},
+		{17, "go.missing-metadata", CheckRule},
+		{23, "go.missing-metadata", CheckDuplicateID},
+		{28, "go.missing-metadata", CheckMetadata},
+		{28, "go.missing-metadata", CheckMetadata},
+		{28, "go.missing-metadata", CheckMetadata},
+		{28, "go.missing-metadata", CheckMetadata},
+		{28, "go.missing-metadata", CheckMetadata},
+	}
+	var gotDiags []diag
+	for _, d := range got {
+		gotDiags = append(gotDiags, diag{d.Line, d.RuleID, d.Check})
+	}
+	if !reflect.DeepEqual(gotDiags, want) {
+		for _, d := range got {
+			t.Log(d)
+		}
+		t.Errorf("Lint() = %v, want %v", gotDiags, want)
+	}
+}
+
+func TestPolicy_LintData(t *testing.T) {
+	data := []byte(`
+rules:
+  - id: py.taint
+    message: $X reaches $SINK
+    mode: taint
+    languages: [python]
+    pattern-sources:
+      - pattern: request.$X
+    pattern-sinks:
+      - pattern: eval(...)
+`)
+	p := Policy{
+		IDPattern:                 regexp.MustCompile(`^py\.`),
+		BoundMessageMetavariables: true,
+	}
+	got, err := p.LintData("taint.yaml", data)
+	if err != nil {
+		t.Fatalf("LintData() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Check != CheckMessage || got[0].Line != 4 {
+		t.Errorf("LintData() = %v", got)
+	}
+	want := "taint.yaml:4:14: [message] py.taint: message references unbound metavariable $SINK"
+	if got[0].String() != want {
+		t.Errorf("Diagnostic.String() = %q, want %q", got[0].String(), want)
+	}
+
+	if _, err := p.LintData("bad.yaml", []byte("foo: bar\n")); err == nil {
+		t.Errorf("LintData() error = nil, want an error for a document without rules")
+	}
+}
+
+func TestPolicy_LintData_Operators(t *testing.T) {
+	data := []byte(`
+rules:
+  - id: py.redos
+    message: Regular expression $RE is vulnerable to ReDoS
+    languages: [python]
+    severity: WARNING
+    patterns:
+      - pattern: re.compile($RE)
+      - metavariable-analysis:
+          analyzer: redos
+          metavariable: $RE
+  - id: java.string-equals
+    message: Compare strings with equals()
+    languages: [java]
+    severity: WARNING
+    patterns:
+      - pattern: $X == $Y
+      - metavariable-type:
+          metavariable: $X
+          type: String
+`)
+	got, err := DefaultPolicy().LintData("operators.yaml", data)
+	if err != nil {
+		t.Fatalf("LintData() error = %v", err)
+	}
+	for _, d := range got {
+		if d.Check == CheckRule {
+			t.Errorf("LintData() = %v", d)
+		}
+	}
+}
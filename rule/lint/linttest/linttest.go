@@ -0,0 +1,24 @@
+// Package linttest runs the rule linter in Go tests.
+package linttest
+
+import (
+	"testing"
+
+	"github.com/parsiya/semgrep_go/rule/lint"
+)
+
+// Lint lints the rule files and reports every diagnostic as a test error.
+//
+//	func TestRules(t *testing.T) {
+//		linttest.Lint(t, lint.DefaultPolicy(), "rules")
+//	}
+func Lint(t testing.TB, p lint.Policy, paths ...string) {
+	t.Helper()
+	diags, err := p.Lint(paths...)
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+	for _, d := range diags {
+		t.Error(d.String())
+	}
+}
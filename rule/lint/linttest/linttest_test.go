@@ -0,0 +1,15 @@
+package linttest
+
+import (
+	"testing"
+
+	"github.com/parsiya/semgrep_go/rule/lint"
+)
+
+func TestLint_Registry(t *testing.T) {
+	// Rules from the Semgrep registry follow the default policy.
+	Lint(t, lint.DefaultPolicy(),
+		"../../testdata/dangerous-exec-command.yaml",
+		"../../testdata/tainted-sql-string.yaml",
+	)
+}
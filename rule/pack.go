@@ -0,0 +1,323 @@
+package rule
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Pack is a set of rules gathered from files, directories, archives, and
+// fs.FS. Rules are deduplicated by id, the first rule with an id is kept.
+// Pass the selected rules to run.Options.SetRules to scan with them. Semgrep
+// gets them in a temp file that is deleted after the run.
+type Pack struct {
+	rules      []Rule
+	ids        map[string]bool
+	duplicates []string
+}
+
+// NewPack returns an empty Pack.
+func NewPack() *Pack {
+	return &Pack{ids: map[string]bool{}}
+}
+
+// Add adds rules to the pack. Rules with an id that is already in the pack
+// are skipped.
+func (p *Pack) Add(rules ...Rule) {
+	for _, r := range rules {
+		if p.ids[r.ID] {
+			p.duplicates = append(p.duplicates, r.ID)
+			continue
+		}
+		p.ids[r.ID] = true
+		p.rules = append(p.rules, r)
+	}
+}
+
+// Rules returns all the rules in the order they were added.
+func (p *Pack) Rules() []Rule {
+	return p.rules
+}
+
+// Duplicates returns the ids of the rules that were skipped because the pack
+// already had a rule with the same id.
+func (p *Pack) Duplicates() []string {
+	return p.duplicates
+}
+
+// AddFile adds the rules in a YAML file.
+func (p *Pack) AddFile(file string) error {
+	rules, err := ParseFile(file)
+	if err != nil {
+		return err
+	}
+	p.Add(rules...)
+	return nil
+}
+
+// AddDir adds the rules in all the YAML files in the directory and its
+// subdirectories. Similar to Semgrep, hidden directories and `.test.yaml`
+// files are skipped.
+func (p *Pack) AddDir(dir string) error {
+	if err := p.AddFS(os.DirFS(dir), "."); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	return nil
+}
+
+// AddFS adds the rules in all the YAML files under root in fsys. It can be
+// used with embed.FS:
+//
+//	//go:embed rules
+//	var rules embed.FS
+//
+//	err := pack.AddFS(rules, "rules")
+func (p *Pack) AddFS(fsys fs.FS, root string) error {
+	return fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name != root && isHidden(name) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isRulePath(name) {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		rules, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		p.Add(rules...)
+		return nil
+	})
+}
+
+// AddTar adds the rules in all the YAML files in a tar archive. The archive
+// can be gzipped.
+func (p *Pack) AddTar(r io.Reader) error {
+	br := bufio.NewReader(r)
+	// gzip magic bytes.
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || !isRulePath(hdr.Name) || hiddenDir(hdr.Name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		rules, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		p.Add(rules...)
+	}
+}
+
+// AddZip adds the rules in all the YAML files in a zip archive.
+func (p *Pack) AddZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	return p.AddFS(zr, ".")
+}
+
+// AddArchive adds the rules in an archive file. The format is detected from
+// the extension: `.zip`, `.tar`, `.tar.gz`, or `.tgz`.
+func (p *Pack) AddArchive(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	lower := strings.ToLower(file)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		err = p.AddZip(f, info.Size())
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"),
+		strings.HasSuffix(lower, ".tgz"):
+		err = p.AddTar(f)
+	default:
+		return fmt.Errorf("%s: unsupported archive format", file)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// Select returns the rules that pass the filter.
+func (p *Pack) Select(f Filter) []Rule {
+	var selected []Rule
+	for _, r := range p.rules {
+		if f.Match(r) {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// isRulePath returns true if the file is a YAML file and not a Semgrep test
+// file, e.g., `rule.test.yaml`.
+func isRulePath(name string) bool {
+	lower := strings.ToLower(name)
+	switch path.Ext(lower) {
+	case ".yaml", ".yml":
+	default:
+		return false
+	}
+	return !strings.HasSuffix(lower, ".test.yaml") && !strings.HasSuffix(lower, ".test.yml")
+}
+
+// isHidden returns true if the last element of the slash separated path
+// starts with a dot.
+func isHidden(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(base, ".") && base != "." && base != ".."
+}
+
+// hiddenDir returns true if any directory in the slash separated path is
+// hidden.
+func hiddenDir(name string) bool {
+	for dir := path.Dir(filepath.ToSlash(name)); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if isHidden(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------
+
+// Filter selects rules. A rule passes if it matches Include and doesn't match
+// Exclude.
+type Filter struct {
+	Include Selector
+	Exclude Selector
+}
+
+// Match returns true if the rule passes the filter. An empty Include matches
+// all rules.
+func (f Filter) Match(r Rule) bool {
+	return f.Include.all(r) && !f.Exclude.any(r)
+}
+
+// Selector matches rules. Each field is a list of alternatives, empty fields
+// are ignored.
+type Selector struct {
+	// Globs for the rule id in path.Match syntax, e.g., `go.lang.security.*`.
+	IDs []string
+	// Languages in the rule's `languages`.
+	Languages []string
+	// Severities of the rule.
+	Severities []Severity
+	// Metadata keys and globs for their values in path.Match syntax. If the
+	// value is a list, any item can match. E.g., `{"cwe": "CWE-89:*"}`.
+	Metadata map[string]string
+}
+
+// all returns true if the rule matches all the non-empty fields.
+func (s Selector) all(r Rule) bool {
+	return (len(s.IDs) == 0 || s.matchID(r)) &&
+		(len(s.Languages) == 0 || s.matchLanguage(r)) &&
+		(len(s.Severities) == 0 || s.matchSeverity(r)) &&
+		(len(s.Metadata) == 0 || s.matchMetadata(r))
+}
+
+// any returns true if the rule matches any of the non-empty fields.
+func (s Selector) any(r Rule) bool {
+	return (len(s.IDs) > 0 && s.matchID(r)) ||
+		(len(s.Languages) > 0 && s.matchLanguage(r)) ||
+		(len(s.Severities) > 0 && s.matchSeverity(r)) ||
+		(len(s.Metadata) > 0 && s.matchMetadata(r))
+}
+
+func (s Selector) matchID(r Rule) bool {
+	for _, glob := range s.IDs {
+		if ok, _ := path.Match(glob, r.ID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Selector) matchLanguage(r Rule) bool {
+	for _, want := range s.Languages {
+		for _, lang := range r.Languages {
+			if strings.EqualFold(want, lang) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s Selector) matchSeverity(r Rule) bool {
+	for _, sev := range s.Severities {
+		if sev == r.Severity {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMetadata returns true if any of the metadata keys matches.
+func (s Selector) matchMetadata(r Rule) bool {
+	for key, glob := range s.Metadata {
+		v, ok := r.Metadata[key]
+		if !ok {
+			continue
+		}
+		values, isList := v.([]interface{})
+		if !isList {
+			values = []interface{}{v}
+		}
+		for _, value := range values {
+			if ok, _ := path.Match(glob, fmt.Sprint(value)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package rule
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+//go:embed testdata
+var testdataFS embed.FS
+
+// All the rule ids in testdata.
+var testdataIDs = []string{
+	"go.lang.security.audit.dangerous-exec-command.dangerous-exec-command",
+	"javascript.lang.security.audit.incomplete-sanitization.incomplete-sanitization",
+	"javascript.express.security.audit.express-large-port",
+	"python.django.security.injection.tainted-sql-string.tainted-sql-string",
+}
+
+func ids(rules []Rule) []string {
+	var ids []string
+	for _, r := range rules {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// testdataFiles returns the name and content of the files in testdata.
+func testdataFiles(t *testing.T) map[string][]byte {
+	files := map[string][]byte{}
+	entries, err := testdataFS.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		data, err := testdataFS.ReadFile("testdata/" + e.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		files["rules/"+e.Name()] = data
+	}
+	// These must be skipped.
+	files["rules/.hidden/a.yaml"] = []byte("not: [a rule")
+	files["rules/foo.test.yaml"] = []byte("not: [a rule")
+	files["rules/README.md"] = []byte("# rules")
+	return files
+}
+
+func TestPack_Sources(t *testing.T) {
+	files := testdataFiles(t)
+	dir := t.TempDir()
+
+	// Directory.
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Archives.
+	var tarBuf, zipBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	zw := zip.NewWriter(&zipBuf)
+	// Sort the files so the rules are in the same order in all sources.
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(data)
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	tw.Close()
+	zw.Close()
+	var tgzBuf bytes.Buffer
+	gz := gzip.NewWriter(&tgzBuf)
+	gz.Write(tarBuf.Bytes())
+	gz.Close()
+	for name, data := range map[string][]byte{"rules.tar": tarBuf.Bytes(), "rules.tgz": tgzBuf.Bytes(), "rules.zip": zipBuf.Bytes()} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		add  func(p *Pack) error
+	}{
+		{"dir", func(p *Pack) error { return p.AddDir(filepath.Join(dir, "rules")) }},
+		{"embed.FS", func(p *Pack) error { return p.AddFS(testdataFS, "testdata") }},
+		{"fs.FS", func(p *Pack) error {
+			mapFS := fstest.MapFS{}
+			for name, data := range files {
+				mapFS[name] = &fstest.MapFile{Data: data}
+			}
+			return p.AddFS(mapFS, "rules")
+		}},
+		{"tar", func(p *Pack) error { return p.AddArchive(filepath.Join(dir, "rules.tar")) }},
+		{"tar.gz", func(p *Pack) error { return p.AddArchive(filepath.Join(dir, "rules.tgz")) }},
+		{"zip", func(p *Pack) error { return p.AddArchive(filepath.Join(dir, "rules.zip")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPack()
+			if err := tt.add(p); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			// Add them again to check deduplication.
+			if err := tt.add(p); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got := ids(p.Rules()); !reflect.DeepEqual(got, testdataIDs) {
+				t.Errorf("Rules() = %v, want %v", got, testdataIDs)
+			}
+			if got := p.Duplicates(); !reflect.DeepEqual(got, testdataIDs) {
+				t.Errorf("Duplicates() = %v, want %v", got, testdataIDs)
+			}
+		})
+	}
+
+	if err := NewPack().AddArchive("rules.rar"); err == nil {
+		t.Errorf("AddArchive() error = nil, want an error for an unsupported format")
+	}
+	if err := NewPack().AddFS(fstest.MapFS{"bad.yaml": {Data: []byte("foo: bar")}}, "."); err == nil {
+		t.Errorf("AddFS() error = nil, want an error for an invalid rule file")
+	}
+}
+
+func TestPack_Select(t *testing.T) {
+	p := NewPack()
+	if err := p.AddFS(testdataFS, "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{
+			name: "all",
+			want: testdataIDs,
+		},
+		{
+			name:   "include id",
+			filter: Filter{Include: Selector{IDs: []string{"javascript.*", "go.*"}}},
+			want:   testdataIDs[:3],
+		},
+		{
+			name:   "include language and severity",
+			filter: Filter{Include: Selector{Languages: []string{"JavaScript", "python"}, Severities: []Severity{SeverityError, SeverityWarning}}},
+			want:   []string{testdataIDs[1], testdataIDs[3]},
+		},
+		{
+			name:   "include metadata",
+			filter: Filter{Include: Selector{Metadata: map[string]string{"cwe": "CWE-89:*"}}},
+			want:   testdataIDs[3:],
+		},
+		{
+			name: "exclude",
+			filter: Filter{Exclude: Selector{
+				Severities: []Severity{SeverityInfo},
+				Metadata:   map[string]string{"confidence": "LOW"},
+			}},
+			want: testdataIDs[3:],
+		},
+		{
+			name: "include and exclude",
+			filter: Filter{
+				Include: Selector{Languages: []string{"js", "javascript"}},
+				Exclude: Selector{IDs: []string{"*.express-*"}},
+			},
+			want: testdataIDs[1:2],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(p.Select(tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+// Package rule has Go types for Semgrep rules and a YAML codec for them. See
+// https://semgrep.dev/docs/writing-rules/rule-syntax for the syntax.
+package rule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity enums.
+type Severity string
+
+const (
+	SeverityInfo       Severity = "INFO"
+	SeverityWarning    Severity = "WARNING"
+	SeverityError      Severity = "ERROR"
+	SeverityInventory  Severity = "INVENTORY"
+	SeverityExperiment Severity = "EXPERIMENT"
+	// Used by newer versions of Semgrep.
+	SeverityLow      Severity = "LOW"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityHigh     Severity = "HIGH"
+	SeverityCritical Severity = "CRITICAL"
+)
+
+// Mode enums. An empty mode is search.
+type Mode string
+
+const (
+	SearchMode  Mode = "search"
+	TaintMode   Mode = "taint"
+	JoinMode    Mode = "join"
+	ExtractMode Mode = "extract"
+)
+
+// File is a Semgrep rule file.
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a Semgrep rule.
+type Rule struct {
+	ID        string   `yaml:"id"`
+	Message   string   `yaml:"message,omitempty"`
+	Severity  Severity `yaml:"severity,omitempty"`
+	Languages []string `yaml:"languages,omitempty"`
+	Mode      Mode     `yaml:"mode,omitempty"`
+
+	// Search mode. Only one of these should be set.
+	Pattern       string   `yaml:"pattern,omitempty"`
+	Patterns      []Clause `yaml:"patterns,omitempty"`
+	PatternEither []Clause `yaml:"pattern-either,omitempty"`
+	PatternRegex  string   `yaml:"pattern-regex,omitempty"`
+
+	// Taint mode.
+	PatternSources     []Clause `yaml:"pattern-sources,omitempty"`
+	PatternSinks       []Clause `yaml:"pattern-sinks,omitempty"`
+	PatternSanitizers  []Clause `yaml:"pattern-sanitizers,omitempty"`
+	PatternPropagators []Clause `yaml:"pattern-propagators,omitempty"`
+
+	// Autofix.
+	Fix      string    `yaml:"fix,omitempty"`
+	FixRegex *FixRegex `yaml:"fix-regex,omitempty"`
+
+	// Files to include and exclude.
+	Paths *Paths `yaml:"paths,omitempty"`
+
+	// Freeform metadata. Returned in CliMatch.Extra.Metadata.
+	Metadata map[string]interface{} `yaml:"metadata,omitempty"`
+
+	// Rule options. E.g., `symbolic_propagation: true`.
+	Options map[string]interface{} `yaml:"options,omitempty"`
+
+	// The Semgrep versions that support this rule.
+	MinVersion string `yaml:"min-version,omitempty"`
+	MaxVersion string `yaml:"max-version,omitempty"`
+
+	// Keys that are not in the struct. They're kept so rules round-trip.
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// Clause is an item in `patterns`, `pattern-either`, or the taint mode lists.
+// Each clause should only have one operator set, taint clauses can also have
+// the taint options.
+type Clause struct {
+	Pattern          string   `yaml:"pattern,omitempty"`
+	PatternNot       string   `yaml:"pattern-not,omitempty"`
+	PatternInside    string   `yaml:"pattern-inside,omitempty"`
+	PatternNotInside string   `yaml:"pattern-not-inside,omitempty"`
+	PatternRegex     string   `yaml:"pattern-regex,omitempty"`
+	PatternNotRegex  string   `yaml:"pattern-not-regex,omitempty"`
+	Patterns         []Clause `yaml:"patterns,omitempty"`
+	PatternEither    []Clause `yaml:"pattern-either,omitempty"`
+
+	// Metavariable operators.
+	FocusMetavariable      StringList              `yaml:"focus-metavariable,omitempty"`
+	MetavariableRegex      *MetavariableRegex      `yaml:"metavariable-regex,omitempty"`
+	MetavariablePattern    *MetavariablePattern    `yaml:"metavariable-pattern,omitempty"`
+	MetavariableComparison *MetavariableComparison `yaml:"metavariable-comparison,omitempty"`
+	MetavariableAnalysis   *MetavariableAnalysis   `yaml:"metavariable-analysis,omitempty"`
+	MetavariableType       *MetavariableType       `yaml:"metavariable-type,omitempty"`
+
+	// Taint options for sources, sinks, and sanitizers.
+	Label          string      `yaml:"label,omitempty"`
+	Requires       string      `yaml:"requires,omitempty"`
+	Exact          *bool       `yaml:"exact,omitempty"`
+	BySideEffect   interface{} `yaml:"by-side-effect,omitempty"`
+	NotConflicting *bool       `yaml:"not-conflicting,omitempty"`
+
+	// Taint options for propagators.
+	From string `yaml:"from,omitempty"`
+	To   string `yaml:"to,omitempty"`
+
+	// Keys that are not in the struct. They're kept so rules round-trip.
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// MetavariableRegex is `metavariable-regex`.
+type MetavariableRegex struct {
+	Metavariable        string `yaml:"metavariable"`
+	Regex               string `yaml:"regex"`
+	ConstantPropagation *bool  `yaml:"constant-propagation,omitempty"`
+}
+
+// MetavariablePattern is `metavariable-pattern`. The embedded clause has the
+// pattern operators.
+type MetavariablePattern struct {
+	Metavariable string `yaml:"metavariable"`
+	Language     string `yaml:"language,omitempty"`
+	Clause       `yaml:",inline"`
+}
+
+// MetavariableComparison is `metavariable-comparison`.
+type MetavariableComparison struct {
+	Metavariable string `yaml:"metavariable,omitempty"`
+	Comparison   string `yaml:"comparison"`
+	Base         *int   `yaml:"base,omitempty"`
+	Strip        *bool  `yaml:"strip,omitempty"`
+}
+
+// MetavariableAnalysis is `metavariable-analysis`. The analyzer is `redos` or
+// `entropy`.
+type MetavariableAnalysis struct {
+	Analyzer     string                 `yaml:"analyzer"`
+	Metavariable string                 `yaml:"metavariable"`
+	Options      map[string]interface{} `yaml:"options,omitempty"`
+}
+
+// MetavariableType is `metavariable-type`. Use Type for one type and Types for
+// a list.
+type MetavariableType struct {
+	Metavariable string   `yaml:"metavariable"`
+	Type         string   `yaml:"type,omitempty"`
+	Types        []string `yaml:"types,omitempty"`
+	Language     string   `yaml:"language,omitempty"`
+}
+
+// FixRegex is `fix-regex`.
+type FixRegex struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+	Count       *int   `yaml:"count,omitempty"`
+}
+
+// Paths is the `paths` key.
+type Paths struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// StringList is a list of strings that can be a single string in YAML. E.g.,
+// `focus-metavariable: $X` or `focus-metavariable: [$X, $Y]`.
+type StringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = StringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler. A single item is a string.
+func (s StringList) MarshalYAML() (interface{}, error) {
+	if len(s) == 1 {
+		return s[0], nil
+	}
+	return []string(s), nil
+}
+
+// ----------
+
+// Parse parses Semgrep rules. The data can have multiple YAML documents
+// separated by `---`. A document is either a rule file (a mapping with a
+// `rules` key) or a single rule.
+func Parse(data []byte) ([]Rule, error) {
+	nodes, err := Nodes(data)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]Rule, 0, len(nodes))
+	for _, n := range nodes {
+		var r Rule
+		if err := n.Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to parse the rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Nodes returns the mapping node of each rule in data without decoding them.
+// Documents are handled like Parse. Use it to get the position of a rule or to
+// keep keys that Rule doesn't know about.
+func Nodes(data []byte) ([]*yaml.Node, error) {
+	var nodes []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the rules: %w", err)
+		}
+		// Skip empty documents.
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+		root := doc.Content[0]
+		if root.Kind == yaml.MappingNode {
+			if _, rules := Lookup(root, "rules"); rules != nil {
+				if rules.Kind != yaml.SequenceNode {
+					return nil, fmt.Errorf("line %d: `rules` must be a list", rules.Line)
+				}
+				nodes = append(nodes, rules.Content...)
+				continue
+			}
+			if _, id := Lookup(root, "id"); id != nil {
+				nodes = append(nodes, root)
+				continue
+			}
+		}
+		return nil, fmt.Errorf("line %d: expected a mapping with a `rules` or `id` key", root.Line)
+	}
+	return nodes, nil
+}
+
+// ParseFile reads and parses a rule file.
+func ParseFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Marshal returns the rules as a rule file.
+func Marshal(rules []Rule) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(File{Rules: rules}); err != nil {
+		return nil, fmt.Errorf("failed to marshal the rules: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal the rules: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Lookup returns the key and value nodes of the key in a mapping node. Both
+// are nil if the node is not a mapping or doesn't have the key.
+func Lookup(mapping *yaml.Node, key string) (k, v *yaml.Node) {
+	if mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	// Mapping nodes store keys and values in alternating order.
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
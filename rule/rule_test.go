@@ -0,0 +1,106 @@
+package rule
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFile_RoundTrip(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no test rules: %v", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			rules, err := ParseFile(file)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			data, err := Marshal(rules)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v\n%s", err, data)
+			}
+			if !reflect.DeepEqual(got, rules) {
+				t.Errorf("rules changed after a round trip:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestParseFile_Fields(t *testing.T) {
+	rules, err := ParseFile("testdata/tainted-sql-string.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rules[0]
+	if r.Mode != TaintMode || r.Severity != SeverityError || !reflect.DeepEqual(r.Languages, []string{"python"}) {
+		t.Errorf("ParseFile() = %+v", r)
+	}
+	if len(r.PatternSources) != 2 || r.PatternSources[0].Label != "USER" || r.PatternSources[1].BySideEffect != true {
+		t.Errorf("ParseFile() sources = %+v", r.PatternSources)
+	}
+	if r.PatternPropagators[0].From != "$ITEM" || r.PatternPropagators[0].To != "$LIST" {
+		t.Errorf("ParseFile() propagators = %+v", r.PatternPropagators)
+	}
+	sink := r.PatternSinks[0]
+	if sink.Requires != "USER" || sink.Exact == nil || *sink.Exact {
+		t.Errorf("ParseFile() sink = %+v", sink)
+	}
+	if mr := sink.Patterns[1].MetavariableRegex; mr == nil || mr.Metavariable != "$SQLSTR" {
+		t.Errorf("ParseFile() metavariable-regex = %+v", mr)
+	}
+
+	rules, err = ParseFile("testdata/javascript-misc.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := rules[0].Patterns[1].MetavariablePattern
+	if mp == nil || mp.Language != "generic" || len(mp.Patterns) != 1 || mp.Patterns[0].PatternRegex == "" {
+		t.Errorf("ParseFile() metavariable-pattern = %+v", mp)
+	}
+	if !reflect.DeepEqual(rules[0].Patterns[2].FocusMetavariable, StringList{"$CHAR"}) {
+		t.Errorf("ParseFile() focus-metavariable = %v", rules[0].Patterns[2].FocusMetavariable)
+	}
+	if rules[0].FixRegex == nil || *rules[0].FixRegex.Count != 1 || rules[0].Paths.Exclude[0] != "*.min.js" {
+		t.Errorf("ParseFile() fix-regex and paths = %+v %+v", rules[0].FixRegex, rules[0].Paths)
+	}
+	if rules[1].Extra["custom-key"] != "kept" || rules[1].MinVersion != "1.40.0" {
+		t.Errorf("ParseFile() extra = %v", rules[1].Extra)
+	}
+}
+
+func TestParse_Documents(t *testing.T) {
+	data := []byte("id: a\npattern: a()\n---\nrules:\n  - id: b\n    pattern: b()\n---\n")
+	rules, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(rules) != 2 || rules[0].ID != "a" || rules[1].ID != "b" {
+		t.Errorf("Parse() = %+v", rules)
+	}
+	if _, err := Parse([]byte("foo: bar\n")); err == nil {
+		t.Errorf("Parse() error = nil, want an error for a document without rules")
+	}
+}
+
+func TestNodes(t *testing.T) {
+	data := []byte("id: a\npattern: a()\n---\nrules:\n  - id: b\n    custom: kept\n")
+	nodes, err := Nodes(data)
+	if err != nil {
+		t.Fatalf("Nodes() error = %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].Line != 1 || nodes[1].Line != 5 {
+		t.Fatalf("Nodes() = %d nodes", len(nodes))
+	}
+	if k, v := Lookup(nodes[1], "custom"); k == nil || v.Value != "kept" {
+		t.Errorf("Lookup() = %v, %v", k, v)
+	}
+	if _, err := Nodes([]byte("rules: foo\n")); err == nil {
+		t.Errorf("Nodes() error = nil, want an error for `rules` that is not a list")
+	}
+}
@@ -0,0 +1,241 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parsiya/semgrep_go/output"
+)
+
+// Job is a single scan in a Batch.
+type Job struct {
+	// The name of the job. Should be unique, it's used to disambiguate paths in
+	// the merged output. If empty, "job-[index]" is used.
+	Name string
+
+	// The rules and paths for this scan. They replace Rules and Paths in the
+	// Batch options.
+	Rules []string
+	Paths []string
+}
+
+// JobResult is the result of a Job.
+type JobResult struct {
+	Job Job
+
+	// The deserialized output. Might be set even if Err is not nil, e.g.,
+	// findings with `--error`.
+	Output output.Output
+
+	// The error from RunJSONContext. If the batch was canceled before the job
+	// started, it's a *ContextError.
+	Err error
+
+	// How long the scan took.
+	Duration time.Duration
+}
+
+// Batch runs many scans in parallel with a bounded worker pool.
+type Batch struct {
+	// The options for every job. Rules and Paths are replaced by the job's.
+	// Options.Jobs is the number of CPUs each scan uses (`--jobs`). If zero,
+	// the CPU budget is divided between the workers.
+	//
+	// The options are shared by the scans that run at the same time:
+	//   - OnLog and OnProgress are called concurrently from multiple scans and
+	//     must be safe for concurrent use.
+	//   - Writes to LogWriter are serialized by Batch, but the output of
+	//     concurrent scans is interleaved.
+	//   - Env, Extra, and the other slices must not be modified while the
+	//     batch is running.
+	Options Options
+
+	// The scans.
+	Jobs []Job
+
+	// Maximum number of concurrent scans. If zero, it's the number of CPUs.
+	Workers int
+
+	// The total number of CPUs all concurrent scans can use. If zero, it's
+	// runtime.NumCPU(). The number of concurrent scans is reduced so the sum
+	// of `--jobs` doesn't exceed it.
+	CPUs int
+}
+
+// budget returns the number of concurrent scans and `--jobs` for each scan.
+func (b *Batch) budget() (workers, jobs int) {
+	cpus := b.CPUs
+	if cpus <= 0 {
+		cpus = runtime.NumCPU()
+	}
+	workers = b.Workers
+	if workers <= 0 {
+		workers = cpus
+	}
+
+	jobs = b.Options.Jobs
+	if jobs <= 0 {
+		// Divide the budget between the workers.
+		jobs = cpus / workers
+	}
+	if jobs > cpus {
+		jobs = cpus
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// Don't run more scans than the budget allows.
+	if workers > cpus/jobs {
+		workers = cpus / jobs
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers, jobs
+}
+
+// Run executes the jobs and returns the results in the same order as Jobs. If
+// the context is canceled, running scans are killed and the rest are not
+// started.
+func (b *Batch) Run(ctx context.Context) []JobResult {
+	workers, jobs := b.budget()
+	results := make([]JobResult, len(b.Jobs))
+
+	opts := b.Options
+	if opts.LogWriter != nil {
+		opts.LogWriter = &syncWriter{w: opts.LogWriter}
+	}
+
+	// Send the job indexes to the workers.
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = b.runJob(ctx, opts, i, jobs)
+			}
+		}()
+	}
+
+	for i := range b.Jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// runJob executes a single job with a copy of opts.
+func (b *Batch) runJob(ctx context.Context, opts Options, index, jobs int) JobResult {
+	job := b.Jobs[index]
+	if job.Name == "" {
+		job.Name = fmt.Sprintf("job-%d", index)
+	}
+	res := JobResult{Job: job}
+
+	// Don't start new scans after cancellation.
+	if err := ctx.Err(); err != nil {
+		res.Err = &ContextError{Err: err}
+		return res
+	}
+
+	// Replace the rules and paths.
+	opts.Rules = job.Rules
+	opts.Paths = job.Paths
+	opts.Jobs = jobs
+
+	start := time.Now()
+	res.Output, res.Err = opts.RunJSONContext(ctx)
+	res.Duration = time.Since(start)
+	return res
+}
+
+// syncWriter serializes the writes of concurrent scans.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// MergeResults merges the outputs of the jobs into one output with
+// output.Merge. Every path is prefixed with the job name to disambiguate them.
+// Failed jobs are included if they have an output.
+func MergeResults(results []JobResult) output.Output {
+	outs := make([]output.Output, 0, len(results))
+	for _, res := range results {
+		outs = append(outs, prefixPaths(res.Output, res.Job.Name))
+	}
+	return output.Merge(outs...)
+}
+
+// prefixPaths returns a copy of the output where all paths start with prefix.
+// The paths are not cleaned, so `../` in a path can't remove the prefix.
+func prefixPaths(out output.Output, prefix string) output.Output {
+	join := func(p output.Fpath) output.Fpath {
+		return output.Fpath(strings.TrimSuffix(prefix, "/") + "/" + string(p))
+	}
+
+	results := make([]output.CliMatch, len(out.Results))
+	for i, r := range out.Results {
+		r.Path = join(r.Path)
+		results[i] = r
+	}
+	out.Results = results
+
+	errs := make([]output.CliError, len(out.Errors))
+	for i, e := range out.Errors {
+		if e.Path != nil {
+			p := join(*e.Path)
+			e.Path = &p
+		}
+		spans := make([]output.ErrorSpan, len(e.Spans))
+		for j, s := range e.Spans {
+			s.File = join(s.File)
+			spans[j] = s
+		}
+		if e.Spans != nil {
+			e.Spans = spans
+		}
+		errs[i] = e
+	}
+	out.Errors = errs
+
+	scanned := make([]output.Fpath, len(out.Paths.Scanned))
+	for i, p := range out.Paths.Scanned {
+		scanned[i] = join(p)
+	}
+	out.Paths.Scanned = scanned
+
+	skipped := make([]output.SkippedTarget, len(out.Paths.Skipped))
+	for i, s := range out.Paths.Skipped {
+		s.Path = join(s.Path)
+		skipped[i] = s
+	}
+	out.Paths.Skipped = skipped
+
+	if out.Time != nil {
+		profile := *out.Time
+		profile.Targets = make([]output.TargetTimes, len(out.Time.Targets))
+		for i, t := range out.Time.Targets {
+			t.Path = join(t.Path)
+			profile.Targets[i] = t
+		}
+		out.Time = &profile
+	}
+
+	return out
+}
@@ -0,0 +1,164 @@
+package run
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/parsiya/semgrep_go/output"
+)
+
+func TestBatch_budget(t *testing.T) {
+	tests := []struct {
+		name        string
+		batch       Batch
+		wantWorkers int
+		wantJobs    int
+	}{
+		{
+			name:        "divide cpus between workers",
+			batch:       Batch{CPUs: 8, Workers: 4},
+			wantWorkers: 4,
+			wantJobs:    2,
+		},
+		{
+			name:        "jobs limit workers",
+			batch:       Batch{CPUs: 8, Workers: 4, Options: Options{Jobs: 4}},
+			wantWorkers: 2,
+			wantJobs:    4,
+		},
+		{
+			name:        "jobs larger than cpus",
+			batch:       Batch{CPUs: 2, Workers: 4, Options: Options{Jobs: 16}},
+			wantWorkers: 1,
+			wantJobs:    2,
+		},
+		{
+			name:        "more workers than cpus",
+			batch:       Batch{CPUs: 2, Workers: 8},
+			wantWorkers: 2,
+			wantJobs:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workers, jobs := tt.batch.budget()
+			if workers != tt.wantWorkers || jobs != tt.wantJobs {
+				t.Errorf("Batch.budget() = %d, %d, want %d, %d", workers, jobs, tt.wantWorkers, tt.wantJobs)
+			}
+		})
+	}
+}
+
+func TestBatch_Run(t *testing.T) {
+	fixture, err := os.ReadFile("../output/test/test-juice-shop-1.42.0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Track the number of concurrent scans.
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	runner := runnerFunc(func(ctx context.Context, inv *Invocation) (int, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		inv.Stderr.Write([]byte("scanning\n"))
+		time.Sleep(20 * time.Millisecond)
+		inv.Stdout.Write(fixture)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return 0, nil
+	})
+
+	// Not safe for concurrent use, Batch serializes the writes.
+	var logs strings.Builder
+	b := Batch{
+		Options: Options{Runner: runner, Jobs: 2, LogWriter: &logs},
+		Jobs: []Job{
+			{Name: "repo-a", Rules: []string{"p/default"}, Paths: []string{"."}},
+			{Name: "repo-b", Rules: []string{"p/default"}, Paths: []string{"."}},
+			{Rules: []string{"p/default"}, Paths: []string{"."}},
+			{Name: "repo-d", Rules: []string{"p/default"}, Paths: []string{"."}},
+		},
+		Workers: 4,
+		CPUs:    4,
+	}
+	results := b.Run(context.Background())
+
+	if maxRunning != 2 {
+		t.Errorf("max concurrent scans = %d, want %d", maxRunning, 2)
+	}
+	if len(results) != 4 {
+		t.Fatalf("len(Batch.Run()) = %d, want %d", len(results), 4)
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			t.Errorf("job %s error = %v", res.Job.Name, res.Err)
+		}
+	}
+	if got := strings.Count(logs.String(), "scanning\n"); got != 4 {
+		t.Errorf("LogWriter got %d lines, want 4", got)
+	}
+	if results[2].Job.Name != "job-2" {
+		t.Errorf("default job name = %q, want %q", results[2].Job.Name, "job-2")
+	}
+
+	merged := MergeResults(results)
+	perJob := len(results[0].Output.Results)
+	if len(merged.Results) != 4*perJob {
+		t.Errorf("len(MergeResults().Results) = %d, want %d", len(merged.Results), 4*perJob)
+	}
+	want := "repo-b/" + results[1].Output.Results[0].FilePath()
+	if got := merged.Results[perJob].FilePath(); got != want {
+		t.Errorf("merged path = %q, want %q", got, want)
+	}
+	// The job's output must not be modified.
+	if results[1].Output.Results[0].FilePath() == want {
+		t.Errorf("MergeResults() modified the job output")
+	}
+}
+
+func Test_prefixPaths(t *testing.T) {
+	out := output.Output{
+		Results: []output.CliMatch{{Path: "../../etc/passwd"}},
+		Time:    &output.Profile{Targets: []output.TargetTimes{{Path: "src/main.go"}}},
+	}
+	got := prefixPaths(out, "repo-a")
+	if p := got.Results[0].FilePath(); p != "repo-a/../../etc/passwd" {
+		t.Errorf("prefixPaths() result path = %q", p)
+	}
+	if p := got.Time.Targets[0].Path; p != "repo-a/src/main.go" {
+		t.Errorf("prefixPaths() target path = %q", p)
+	}
+	if out.Time.Targets[0].Path != "src/main.go" {
+		t.Errorf("prefixPaths() modified the profile")
+	}
+}
+
+func TestBatch_Run_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	b := Batch{
+		Options: Options{Runner: runnerFunc(func(ctx context.Context, inv *Invocation) (int, error) {
+			t.Errorf("a scan was started after cancellation")
+			return 0, nil
+		})},
+		Jobs: []Job{{Name: "a"}, {Name: "b"}},
+	}
+	for _, res := range b.Run(ctx) {
+		if _, ok := res.Err.(*ContextError); !ok {
+			t.Errorf("job %s error = %v, want *ContextError", res.Job.Name, res.Err)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+
+	"github.com/parsiya/semgrep_go/output"
+)
+
+// ErrInvalidOptions is wrapped by the errors returned from Options.Validate.
+var ErrInvalidOptions = errors.New("invalid semgrep options")
+
+// ContextError is returned when Semgrep was stopped because the context passed
+// to RunContext or RunJSONContext was canceled or its deadline passed.
+//
+// Use Timeout() or errors.Is(err, context.DeadlineExceeded) to check for
+// timeouts and errors.Is(err, context.Canceled) for cancellations.
+type ContextError struct {
+	// The context error. Either context.Canceled or context.DeadlineExceeded.
+	Err error
+
+	// Whatever Semgrep wrote to stdout and stderr before it was killed.
+	Stdout []byte
+	Stderr string
+}
+
+func (e *ContextError) Error() string {
+	if e.Timeout() {
+		return fmt.Sprintf("semgrep timed out: %v", e.Err)
+	}
+	return fmt.Sprintf("semgrep was canceled: %v", e.Err)
+}
+
+// Unwrap returns the context error.
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if Semgrep was stopped because the deadline passed.
+func (e *ContextError) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// ----------
+
+// ExitReason is the reason Semgrep failed. It's classified from Semgrep's exit
+// code. See https://semgrep.dev/docs/cli-reference#exit-codes.
+//
+// ExitReason implements error so it can be used as a target for errors.Is:
+//
+//	if errors.Is(err, run.ReasonInvalidPattern) { ... }
+type ExitReason int
+
+const (
+	// The exit code is not documented.
+	ReasonUnknown ExitReason = iota
+	// The Semgrep binary was not found.
+	ReasonNotFound
+	// Semgrep couldn't be started for another reason (e.g., permissions).
+	ReasonNotStarted
+	// Exit code 1: Semgrep ran successfully and found issues with `--error`.
+	ReasonFindings
+	// Exit code 2: Semgrep failed.
+	ReasonFatal
+	// Exit code 3: Invalid syntax of the target code with `--strict`.
+	ReasonInvalidCode
+	// Exit code 4: Invalid pattern in the rule.
+	ReasonInvalidPattern
+	// Exit code 5: Unparseable YAML configuration file.
+	ReasonUnparseableYAML
+	// Exit code 6: The rule needs arbitrary code execution.
+	ReasonNeedArbitraryCodeExec
+	// Exit code 7: Missing configuration.
+	ReasonMissingConfig
+	// Exit code 8: Invalid language in the rule.
+	ReasonInvalidLanguage
+	// Exit code 9: Rule match timeout.
+	ReasonMatchTimeout
+	// Exit code 10: Rule match exceeded the max memory.
+	ReasonMatchMaxMemory
+	// Exit code 11: Lexical error in the target code.
+	ReasonLexicalError
+	// Exit code 12: Too many matches.
+	ReasonTooManyMatches
+	// Exit code 13: Invalid Semgrep App API key.
+	ReasonInvalidAPIKey
+	// Exit code 14: The scan failed.
+	ReasonScanFail
+	// The working directory in Options.Dir doesn't exist or is not a
+	// directory. Semgrep was not started.
+	ReasonBadDir
+)
+
+// exitCodeReasons maps Semgrep's documented exit codes to reasons.
+var exitCodeReasons = map[int]ExitReason{
+	1:  ReasonFindings,
+	2:  ReasonFatal,
+	3:  ReasonInvalidCode,
+	4:  ReasonInvalidPattern,
+	5:  ReasonUnparseableYAML,
+	6:  ReasonNeedArbitraryCodeExec,
+	7:  ReasonMissingConfig,
+	8:  ReasonInvalidLanguage,
+	9:  ReasonMatchTimeout,
+	10: ReasonMatchMaxMemory,
+	11: ReasonLexicalError,
+	12: ReasonTooManyMatches,
+	13: ReasonInvalidAPIKey,
+	14: ReasonScanFail,
+}
+
+// ReasonFromExitCode returns the reason for a Semgrep exit code.
+func ReasonFromExitCode(code int) ExitReason {
+	if r, exists := exitCodeReasons[code]; exists {
+		return r
+	}
+	return ReasonUnknown
+}
+
+// String returns a short description of the reason.
+func (r ExitReason) String() string {
+	switch r {
+	case ReasonNotFound:
+		return "semgrep binary not found"
+	case ReasonNotStarted:
+		return "semgrep could not be started"
+	case ReasonBadDir:
+		return "invalid working directory"
+	case ReasonFindings:
+		return "findings found"
+	case ReasonFatal:
+		return "fatal error"
+	case ReasonInvalidCode:
+		return "invalid syntax in target code"
+	case ReasonInvalidPattern:
+		return "invalid pattern in rule"
+	case ReasonUnparseableYAML:
+		return "unparseable YAML configuration"
+	case ReasonNeedArbitraryCodeExec:
+		return "rule needs arbitrary code execution"
+	case ReasonMissingConfig:
+		return "missing configuration"
+	case ReasonInvalidLanguage:
+		return "invalid language in rule"
+	case ReasonMatchTimeout:
+		return "rule match timeout"
+	case ReasonMatchMaxMemory:
+		return "rule match exceeded max memory"
+	case ReasonLexicalError:
+		return "lexical error in target code"
+	case ReasonTooManyMatches:
+		return "too many matches"
+	case ReasonInvalidAPIKey:
+		return "invalid API key"
+	case ReasonScanFail:
+		return "scan failed"
+	default:
+		return "unknown error"
+	}
+}
+
+func (r ExitReason) Error() string {
+	return r.String()
+}
+
+// ExecError is returned when Semgrep could not be started or exited with a
+// non-zero exit code.
+//
+// Use errors.As to get the details and errors.Is with an ExitReason to check
+// the reason.
+type ExecError struct {
+	// Semgrep's exit code. -1 if Semgrep didn't start or was killed by a
+	// signal.
+	ExitCode int
+
+	// The classified reason.
+	Reason ExitReason
+
+	// The exact argv, including the Semgrep binary.
+	Args []string
+
+	// Captured stdout and stderr.
+	Stdout []byte
+	Stderr string
+
+	// Errors that Semgrep printed in the JSON output before failing. Empty if
+	// the output was not JSON.
+	Errors []output.CliError
+
+	// The error returned by the Runner if Semgrep didn't start. E.g.,
+	// exec.ErrNotFound.
+	Err error
+}
+
+// newExecError creates an *ExecError and classifies the reason.
+func newExecError(args []string, exitCode int, err error, stdout []byte, stderr string) *ExecError {
+	e := &ExecError{
+		ExitCode: exitCode,
+		Args:     args,
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Errors:   parseCliErrors(stdout),
+		Err:      err,
+	}
+
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		e.Reason = ReasonNotFound
+	case errors.As(err, &pathErr) && pathErr.Op == "chdir":
+		// The path of the error is the directory.
+		e.Reason = ReasonBadDir
+	case errors.As(err, &pathErr) && len(args) > 0 && pathErr.Path == args[0] &&
+		errors.Is(err, fs.ErrNotExist):
+		// A binary with a path, e.g., `/opt/semgrep/bin/semgrep`.
+		e.Reason = ReasonNotFound
+	case err != nil:
+		e.Reason = ReasonNotStarted
+	default:
+		e.Reason = ReasonFromExitCode(exitCode)
+	}
+	return e
+}
+
+func (e *ExecError) Error() string {
+	// Use the first line of stderr or the first CliError as the message.
+	msg := ""
+	if len(e.Errors) > 0 && e.Errors[0].Message != nil {
+		msg = *e.Errors[0].Message
+	} else if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg = stderr
+	} else if e.Err != nil {
+		msg = e.Err.Error()
+	}
+
+	if e.ExitCode == -1 {
+		return fmt.Sprintf("%s: %s", e.Reason, msg)
+	}
+	return fmt.Sprintf("semgrep exited with code %d (%s): %s", e.ExitCode, e.Reason, msg)
+}
+
+// Unwrap returns the Runner error.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the ExitReason of this error.
+func (e *ExecError) Is(target error) bool {
+	r, ok := target.(ExitReason)
+	return ok && r == e.Reason
+}
+
+// parseCliErrors returns the errors in Semgrep's JSON output. It returns nil if
+// the output is not JSON.
+func parseCliErrors(stdout []byte) []output.CliError {
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return nil
+	}
+	// Only decode the errors, the rest of the output might be incomplete.
+	var partial struct {
+		Errors []output.CliError `json:"errors"`
+	}
+	if err := json.Unmarshal(stdout, &partial); err != nil {
+		return nil
+	}
+	return partial.Errors
+}
@@ -0,0 +1,118 @@
+package run
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOptions_Run_ExecError(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		script     string
+		wantCode   int
+		wantReason ExitReason
+		wantStderr string
+		wantErrors int
+	}{
+		{
+			name:       "findings with --error",
+			script:     "echo '{\"errors\": [], \"results\": [], \"paths\": {\"scanned\": []}}'\nexit 1\n",
+			wantCode:   1,
+			wantReason: ReasonFindings,
+		},
+		{
+			name: "invalid pattern",
+			script: "echo '{\"errors\": [{\"code\": 4, \"level\": \"error\", \"type\": \"Pattern parse error\", \"message\": \"bad pattern\"}], \"results\": [], \"paths\": {\"scanned\": []}}'\n" +
+				"echo 'invalid pattern' >&2\nexit 4\n",
+			wantCode:   4,
+			wantReason: ReasonInvalidPattern,
+			wantStderr: "invalid pattern\n",
+			wantErrors: 1,
+		},
+		{
+			name:       "undocumented exit code",
+			script:     "echo 'oops' >&2\nexit 42\n",
+			wantCode:   42,
+			wantReason: ReasonUnknown,
+			wantStderr: "oops\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeSemgrep(t, tt.script)
+
+			opts := DefaultOptions([]string{"rules.yaml"}, []string{"code"})
+			_, err := opts.Run()
+
+			var execErr *ExecError
+			if !errors.As(err, &execErr) {
+				t.Fatalf("Run() error = %v, want *ExecError", err)
+			}
+			if !errors.Is(err, tt.wantReason) {
+				t.Errorf("errors.Is(err, %v) = false, got reason %v", tt.wantReason, execErr.Reason)
+			}
+			if execErr.ExitCode != tt.wantCode {
+				t.Errorf("ExecError.ExitCode = %d, want %d", execErr.ExitCode, tt.wantCode)
+			}
+			if execErr.Stderr != tt.wantStderr {
+				t.Errorf("ExecError.Stderr = %q, want %q", execErr.Stderr, tt.wantStderr)
+			}
+			if len(execErr.Errors) != tt.wantErrors {
+				t.Errorf("len(ExecError.Errors) = %d, want %d", len(execErr.Errors), tt.wantErrors)
+			}
+			wantArgs := []string{"--config", "rules.yaml", "--metrics=off", "--json", "--debug", "code"}
+			if !reflect.DeepEqual(execErr.Args[1:], wantArgs) {
+				t.Errorf("ExecError.Args = %v, want %v", execErr.Args[1:], wantArgs)
+			}
+		})
+	}
+}
+
+func TestOptions_RunJSON_Findings(t *testing.T) {
+	// Semgrep prints the full JSON output when it exits with 1.
+	fakeSemgrep(t, "echo '{\"version\": \"1.52.0\", \"errors\": [], \"results\": [], \"paths\": {\"scanned\": [\"a.go\"]}}'\nexit 1\n")
+
+	out, err := DefaultOptions([]string{"rules.yaml"}, []string{"code"}).RunJSON()
+	if !errors.Is(err, ReasonFindings) {
+		t.Fatalf("RunJSON() error = %v, want %v", err, ReasonFindings)
+	}
+	if len(out.Paths.Scanned) != 1 {
+		t.Errorf("RunJSON() did not return the output with the error")
+	}
+}
+
+func TestOptions_Run_BadDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs /bin/sh")
+	}
+	dir := filepath.Join(t.TempDir(), "nonexistent")
+	opts := Options{Command: []string{"/bin/sh"}, Dir: dir}
+	_, err := opts.Version()
+	if !errors.Is(err, ReasonBadDir) {
+		t.Fatalf("Version() error = %v, want %v", err, ReasonBadDir)
+	}
+	if !strings.Contains(err.Error(), dir) {
+		t.Errorf("Version() error = %v, want the directory in the message", err)
+	}
+
+	// A missing binary with a path is still not found.
+	opts = Options{Command: []string{filepath.Join(t.TempDir(), "semgrep")}}
+	if _, err := opts.Version(); !errors.Is(err, ReasonNotFound) {
+		t.Errorf("Version() error = %v, want %v", err, ReasonNotFound)
+	}
+}
+
+func TestOptions_Run_NotFound(t *testing.T) {
+	// An empty PATH, Semgrep is nowhere to be found.
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := DefaultOptions([]string{"rules.yaml"}, []string{"code"}).Run()
+	if !errors.Is(err, ReasonNotFound) {
+		t.Errorf("Run() error = %v, want %v", err, ReasonNotFound)
+	}
+}
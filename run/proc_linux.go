@@ -0,0 +1,9 @@
+package run
+
+import "syscall"
+
+// setDeathSignal terminates Semgrep if the thread that started it dies. Semgrep
+// then stops semgrep-core.
+func setDeathSignal(attr *syscall.SysProcAttr) {
+	attr.Pdeathsig = syscall.SIGTERM
+}
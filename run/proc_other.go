@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+
+package run
+
+import "syscall"
+
+// setDeathSignal is not supported outside Linux.
+func setDeathSignal(attr *syscall.SysProcAttr) {}
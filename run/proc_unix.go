@@ -0,0 +1,34 @@
+//go:build !windows
+
+package run
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs the command in a new process group. Semgrep spawns
+// semgrep-core and other children, putting them in the same group allows us to
+// kill all of them together. Semgrep is not in the foreground group anymore, so
+// it's also set to be terminated when we die.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	setDeathSignal(cmd.SysProcAttr)
+}
+
+// killProcessTree kills the process group of a started command.
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	// A negative pid sends the signal to every process in the group.
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if err == syscall.ESRCH {
+		// The group is already gone.
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package run
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Parse progress events from Semgrep's stderr. Semgrep doesn't have a machine
+// readable progress output, so these are derived from the human readable
+// messages. The messages change between versions, use Verbose or Debug
+// verbosity to get more of them.
+
+// ProgressKind is the type of a progress event.
+type ProgressKind int
+
+const (
+	// Semgrep loaded the rules. Progress.Rules is set.
+	RulesLoaded ProgressKind = iota
+	// Semgrep started scanning. Progress.Files is set. Progress.Rules is set if
+	// Semgrep printed it on the same line.
+	ScanStarted
+	// Semgrep's progress bar moved. Progress.Percent is set.
+	ScanProgress
+	// Semgrep finished scanning. Progress.Rules, Progress.Files, and
+	// Progress.Findings are set.
+	ScanFinished
+)
+
+// String returns the name of the kind.
+func (k ProgressKind) String() string {
+	switch k {
+	case RulesLoaded:
+		return "rules loaded"
+	case ScanStarted:
+		return "scan started"
+	case ScanProgress:
+		return "scan progress"
+	case ScanFinished:
+		return "scan finished"
+	default:
+		return "unknown"
+	}
+}
+
+// Progress is a progress event parsed from a line in Semgrep's stderr.
+type Progress struct {
+	Kind ProgressKind
+
+	// Number of rules, files, and findings. Zero if not in the line.
+	Rules    int
+	Files    int
+	Findings int
+
+	// Percentage of the scan that is done, from 0 to 100.
+	Percent int
+
+	// The stderr line that created this event.
+	Line string
+}
+
+var (
+	// "Ran 1062 rules on 1143 files: 67 findings."
+	finishedRegex = regexp.MustCompile(`Ran (\d+) rules? on (\d+) files?: (\d+) findings?`)
+	// "Scanning 1143 files (only git-tracked) with 1062 Code rules:" or
+	// "Scanning 1 file tracked by git with:" in newer versions.
+	scanningRegex = regexp.MustCompile(`Scanning (\d+) files?(?:[^:]*? with (\d+))?`)
+	// "Running 1062 rules..." or "Loaded 1062 rules" in debug logs.
+	rulesRegex = regexp.MustCompile(`(?:Running|Loaded|Loading) (\d+) rules?`)
+	// "  45%|████▌     | 478/1062 tasks" or " ━━━━━━━━━━━━━━━━━━━━ 45% 0:00:10".
+	// Only the two progress bars, "45%" in a log message is not progress.
+	percentRegex = regexp.MustCompile(`^\s*(\d{1,3})%\||[━╸╺]\s*(\d{1,3})%\s+\d+:\d{2}:\d{2}`)
+)
+
+// ParseProgress parses a line from Semgrep's stderr. The second return value is
+// false if the line is not a progress event.
+func ParseProgress(line string) (Progress, bool) {
+	p := Progress{Line: line}
+
+	if m := finishedRegex.FindStringSubmatch(line); m != nil {
+		p.Kind = ScanFinished
+		p.Rules, _ = strconv.Atoi(m[1])
+		p.Files, _ = strconv.Atoi(m[2])
+		p.Findings, _ = strconv.Atoi(m[3])
+		return p, true
+	}
+
+	if m := scanningRegex.FindStringSubmatch(line); m != nil {
+		p.Kind = ScanStarted
+		p.Files, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			p.Rules, _ = strconv.Atoi(m[2])
+		}
+		return p, true
+	}
+
+	if m := rulesRegex.FindStringSubmatch(line); m != nil {
+		p.Kind = RulesLoaded
+		p.Rules, _ = strconv.Atoi(m[1])
+		return p, true
+	}
+
+	if m := percentRegex.FindStringSubmatch(line); m != nil {
+		percent, _ := strconv.Atoi(m[1] + m[2])
+		if percent > 100 {
+			return p, false
+		}
+		p.Kind = ScanProgress
+		p.Percent = percent
+		return p, true
+	}
+
+	return p, false
+}
+
+// ----------
+
+// lineWriter is an io.Writer that calls fn for every line written to it. Both
+// `\n` and `\r` end a line because progress bars redraw the same line with
+// `\r`. Empty lines are skipped.
+type lineWriter struct {
+	buf []byte
+	fn  func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush sends the last line if it didn't end with a newline.
+func (w *lineWriter) Flush() {
+	w.emit(string(w.buf))
+	w.buf = nil
+}
+
+func (w *lineWriter) emit(line string) {
+	line = strings.TrimRight(line, " \t")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	w.fn(line)
+}
@@ -0,0 +1,112 @@
+package run
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   Progress
+		wantOk bool
+	}{
+		{
+			line:   "Running 1062 rules...",
+			want:   Progress{Kind: RulesLoaded, Rules: 1062},
+			wantOk: true,
+		},
+		{
+			line:   "Scanning 1143 files (only git-tracked) with 1062 Code rules:",
+			want:   Progress{Kind: ScanStarted, Files: 1143, Rules: 1062},
+			wantOk: true,
+		},
+		{
+			line:   "  Scanning 1 file tracked by git with:",
+			want:   Progress{Kind: ScanStarted, Files: 1},
+			wantOk: true,
+		},
+		{
+			line:   "  45%|████▌     | 478/1062 tasks",
+			want:   Progress{Kind: ScanProgress, Percent: 45},
+			wantOk: true,
+		},
+		{
+			line:   " ━━━━━━━━━━━━━━━━━━━━ 100% 0:00:10",
+			want:   Progress{Kind: ScanProgress, Percent: 100},
+			wantOk: true,
+		},
+		{
+			line:   "Ran 1062 rules on 1143 files: 67 findings.",
+			want:   Progress{Kind: ScanFinished, Rules: 1062, Files: 1143, Findings: 67},
+			wantOk: true,
+		},
+		{
+			line:   " ━━━━━━━━━╸━━━━━━━━━━ 45% 0:00:04",
+			want:   Progress{Kind: ScanProgress, Percent: 45},
+			wantOk: true,
+		},
+		{
+			line:   "[00.12][DEBUG]: Using config from p/default",
+			wantOk: false,
+		},
+		{
+			line:   "[01.20][DEBUG]: Parsed 45% of the files with tree-sitter",
+			wantOk: false,
+		},
+		{
+			line:   " 50% of the findings are in test files",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := ParseProgress(tt.line)
+			if ok != tt.wantOk {
+				t.Fatalf("ParseProgress() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			tt.want.Line = tt.line
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProgress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions_Run_Streaming(t *testing.T) {
+	fakeSemgrep(t, `echo "Running 2 rules..." >&2
+printf ' 50%%|█    | 1/2 tasks\r100%%|██| 2/2 tasks\r' >&2
+echo "Ran 2 rules on 3 files: 1 findings." >&2
+printf 'no newline' >&2
+echo '{}'
+`)
+
+	var logs strings.Builder
+	var lines []string
+	var kinds []ProgressKind
+
+	opts := DefaultOptions([]string{"rules.yaml"}, []string{"code"})
+	opts.LogWriter = &logs
+	opts.OnLog = func(line string) { lines = append(lines, line) }
+	opts.OnProgress = func(p Progress) { kinds = append(kinds, p.Kind) }
+
+	if _, err := opts.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	wantLines := []string{"Running 2 rules...", " 50%|█    | 1/2 tasks", "100%|██| 2/2 tasks", "Ran 2 rules on 3 files: 1 findings.", "no newline"}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("OnLog lines = %q, want %q", lines, wantLines)
+	}
+	wantKinds := []ProgressKind{RulesLoaded, ScanProgress, ScanProgress, ScanFinished}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Errorf("OnProgress kinds = %v, want %v", kinds, wantKinds)
+	}
+	if !strings.HasPrefix(logs.String(), "Running 2 rules...\n") {
+		t.Errorf("LogWriter = %q", logs.String())
+	}
+}
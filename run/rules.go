@@ -0,0 +1,68 @@
+package run
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/parsiya/semgrep_go/rule"
+	"gopkg.in/yaml.v3"
+)
+
+// The file name of the merged string rules in the temp directory.
+const ruleFileName = "rules.yaml"
+
+// SetRules serializes the rules and passes them to Semgrep as string rules.
+// It replaces any existing value of Rules.
+func (o *Options) SetRules(rules []rule.Rule) error {
+	if len(rules) == 0 {
+		return errors.New("no rules to set")
+	}
+	data, err := rule.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	o.Rules = []string{string(data)}
+	o.StringRule()
+	return nil
+}
+
+// mergeRules merges Semgrep rule documents into one document with a single
+// `rules` key. Each string is parsed with rule.Nodes, so it can have multiple
+// YAML documents and each document is a rule file or a single rule. Keys that
+// rule.Rule doesn't know about are kept.
+func mergeRules(rules []string) ([]byte, error) {
+	var merged []*yaml.Node
+
+	for i, r := range rules {
+		found, err := rule.Nodes([]byte(r))
+		if err != nil {
+			return nil, fmt.Errorf("rule string %d: %w", i, err)
+		}
+		merged = append(merged, found...)
+	}
+
+	if len(merged) == 0 {
+		return nil, errors.New("no rules in the rule strings")
+	}
+
+	// Create `rules: [merged]`.
+	root := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "rules"},
+			{Kind: yaml.SequenceNode, Content: merged},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("couldn't merge the rules: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("couldn't merge the rules: %w", err)
+	}
+	return buf.Bytes(), nil
+}
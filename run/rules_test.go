@@ -0,0 +1,206 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/rule"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_mergeRules(t *testing.T) {
+	ruleFile := `rules:
+  - id: rule-1
+    pattern: foo()
+    message: foo
+    languages: [go]
+    severity: INFO
+`
+	multiDoc := `rules:
+  - id: rule-2
+    pattern: bar()
+    message: bar
+    languages: [go]
+    severity: INFO
+---
+id: rule-3
+pattern: baz()
+message: baz
+languages: [go]
+severity: INFO
+`
+	tests := []struct {
+		name    string
+		rules   []string
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name:    "one rule file",
+			rules:   []string{ruleFile},
+			wantIDs: []string{"rule-1"},
+		},
+		{
+			name:    "multiple strings and documents",
+			rules:   []string{ruleFile, multiDoc},
+			wantIDs: []string{"rule-1", "rule-2", "rule-3"},
+		},
+		{
+			name:    "invalid YAML",
+			rules:   []string{"rules: [\n"},
+			wantErr: true,
+		},
+		{
+			name:    "not a rule",
+			rules:   []string{"foo: bar\n"},
+			wantErr: true,
+		},
+		{
+			name:    "no rules",
+			rules:   []string{""},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeRules(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var parsed struct {
+				Rules []struct {
+					ID string `yaml:"id"`
+				} `yaml:"rules"`
+			}
+			if err := yaml.Unmarshal(got, &parsed); err != nil {
+				t.Fatalf("mergeRules() returned invalid YAML: %v\n%s", err, got)
+			}
+			var ids []string
+			for _, r := range parsed.Rules {
+				ids = append(ids, r.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("mergeRules() ids = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("mergeRules() ids = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+// runnerFunc is a Runner backed by a function.
+type runnerFunc func(ctx context.Context, inv *Invocation) (int, error)
+
+func (f runnerFunc) Run(ctx context.Context, inv *Invocation) (int, error) {
+	return f(ctx, inv)
+}
+
+func TestOptions_StringRule_TempFiles(t *testing.T) {
+	rule := "id: rule-1\npattern: foo()\nmessage: foo\nlanguages: [go]\nseverity: INFO\n"
+
+	tests := []struct {
+		name     string
+		keep     bool
+		exitCode int
+		runErr   error
+	}{
+		{name: "success"},
+		{name: "keep", keep: true},
+		{name: "semgrep failed", exitCode: 2},
+		{name: "semgrep didn't start", runErr: errors.New("boom")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ruleFile string
+			opts := DefaultOptions([]string{rule}, []string{"."})
+			opts.StringRule()
+			opts.KeepTempFiles = tt.keep
+			opts.Runner = runnerFunc(func(ctx context.Context, inv *Invocation) (int, error) {
+				// semgrep --config [file] ...
+				ruleFile = inv.Args[2]
+				info, err := os.Stat(ruleFile)
+				if err != nil {
+					t.Fatalf("rule file doesn't exist during the run: %v", err)
+				}
+				// Windows doesn't have Unix permissions.
+				if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0o600 {
+					t.Errorf("rule file permissions = %v, want %v", perm, os.FileMode(0o600))
+				}
+				return tt.exitCode, tt.runErr
+			})
+
+			opts.Run()
+
+			_, err := os.Stat(filepath.Dir(ruleFile))
+			if tt.keep {
+				if err != nil {
+					t.Errorf("temp directory was deleted with KeepTempFiles")
+				}
+				os.RemoveAll(filepath.Dir(ruleFile))
+				return
+			}
+			if !os.IsNotExist(err) {
+				t.Errorf("temp directory was not deleted after the run")
+			}
+		})
+	}
+}
+
+func TestOptions_StringRule_Panic(t *testing.T) {
+	var ruleFile string
+	opts := DefaultOptions([]string{"id: rule-1\n"}, []string{"."})
+	opts.StringRule()
+	opts.Runner = runnerFunc(func(ctx context.Context, inv *Invocation) (int, error) {
+		ruleFile = inv.Args[2]
+		panic("runner panicked")
+	})
+
+	func() {
+		defer func() { recover() }()
+		opts.Run()
+	}()
+
+	if _, err := os.Stat(filepath.Dir(ruleFile)); !os.IsNotExist(err) {
+		t.Errorf("temp directory was not deleted after a panic")
+	}
+}
+
+func TestOptions_SetRules(t *testing.T) {
+	rules, err := rule.ParseFile("../rule/testdata/javascript-misc.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []rule.Rule
+	opts := DefaultOptions(nil, []string{"."})
+	if err := opts.SetRules(rules); err != nil {
+		t.Fatalf("SetRules() error = %v", err)
+	}
+	opts.Runner = runnerFunc(func(ctx context.Context, inv *Invocation) (int, error) {
+		// semgrep --config [file] ...
+		got, err = rule.ParseFile(inv.Args[2])
+		return 0, err
+	})
+	if _, err := opts.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, rules) {
+		t.Errorf("Semgrep got rules %+v, want %+v", got, rules)
+	}
+
+	if err := opts.SetRules(nil); err == nil {
+		t.Errorf("SetRules(nil) error = nil, want an error")
+	}
+}
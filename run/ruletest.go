@@ -0,0 +1,214 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Run Semgrep's rule tests with `semgrep --test`. Semgrep finds the test
+// targets next to the rules (or in Paths) and compares the findings with the
+// `ruleid:`, `ok:`, `todoruleid:`, and `todook:` annotations in the targets.
+// See https://semgrep.dev/docs/writing-rules/testing-rules.
+
+// The test switch.
+const TestSwitch = "--test"
+
+// TestResults is the JSON output of `semgrep --test --json`.
+type TestResults struct {
+	// The results for each rule file. The key is the rule file path.
+	Results map[string]RuleFileTests `json:"results"`
+
+	// The autofix test results for each rule file.
+	FixtestResults map[string]FixtestResult `json:"fixtest_results"`
+
+	// Rule files without test targets.
+	ConfigMissingTests []string `json:"config_missing_tests"`
+
+	// Rule files with `fix` but no `.fixed` test targets.
+	ConfigMissingFixtests []string `json:"config_missing_fixtests"`
+
+	// Rule files that couldn't be loaded.
+	ConfigWithErrors []TestConfigError `json:"config_with_errors"`
+}
+
+// RuleFileTests are the test results of the rules in a rule file.
+type RuleFileTests struct {
+	// The key is the rule ID.
+	Checks map[string]RuleCheck `json:"checks"`
+}
+
+// RuleCheck is the test result of a single rule.
+type RuleCheck struct {
+	Passed bool `json:"passed"`
+
+	// The expected and reported lines. The key is the test target path.
+	Matches map[string]ExpectedReported `json:"matches"`
+
+	// Errors while running the rule.
+	Errors []interface{} `json:"errors"`
+}
+
+// ExpectedReported has the expected (annotated) and reported (found) lines of
+// a rule in a test target.
+type ExpectedReported struct {
+	ExpectedLines []int `json:"expected_lines"`
+	ReportedLines []int `json:"reported_lines"`
+}
+
+// FixtestResult is the autofix test result of a rule file.
+type FixtestResult struct {
+	Passed bool `json:"passed"`
+}
+
+// TestConfigError is a rule file that couldn't be loaded. The format changed
+// between versions, newer versions have File and Reason, older ones Filename
+// and Error.
+type TestConfigError struct {
+	File     string      `json:"file,omitempty"`
+	Reason   interface{} `json:"reason,omitempty"`
+	Filename string      `json:"filename,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// ----------
+
+// RuleTest is the report of a single rule.
+type RuleTest struct {
+	// The rule ID and the rule file.
+	RuleID   string
+	RuleFile string
+
+	Passed bool
+
+	// The result for each test target, sorted by path.
+	Targets []TargetTest
+}
+
+// TargetTest is the report of a rule in a single test target.
+type TargetTest struct {
+	Path string
+
+	// Lines with `ruleid:` annotations and lines with findings.
+	Expected []int
+	Reported []int
+
+	// Expected lines without findings (false negatives) and findings without
+	// annotations (false positives).
+	Missing    []int
+	Unexpected []int
+}
+
+// Rules returns the report for every rule, sorted by rule file and rule ID.
+func (r TestResults) Rules() []RuleTest {
+	var tests []RuleTest
+	for file, fileTests := range r.Results {
+		for id, check := range fileTests.Checks {
+			rt := RuleTest{RuleID: id, RuleFile: file, Passed: check.Passed}
+			for target, lines := range check.Matches {
+				rt.Targets = append(rt.Targets, TargetTest{
+					Path:       target,
+					Expected:   lines.ExpectedLines,
+					Reported:   lines.ReportedLines,
+					Missing:    difference(lines.ExpectedLines, lines.ReportedLines),
+					Unexpected: difference(lines.ReportedLines, lines.ExpectedLines),
+				})
+			}
+			sort.Slice(rt.Targets, func(i, j int) bool {
+				return rt.Targets[i].Path < rt.Targets[j].Path
+			})
+			tests = append(tests, rt)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].RuleFile != tests[j].RuleFile {
+			return tests[i].RuleFile < tests[j].RuleFile
+		}
+		return tests[i].RuleID < tests[j].RuleID
+	})
+	return tests
+}
+
+// Failed returns the report for the rules that failed.
+func (r TestResults) Failed() []RuleTest {
+	var failed []RuleTest
+	for _, rt := range r.Rules() {
+		if !rt.Passed {
+			failed = append(failed, rt)
+		}
+	}
+	return failed
+}
+
+// Passed returns true if every rule and autofix test passed and all rule files
+// were loaded.
+func (r TestResults) Passed() bool {
+	if len(r.ConfigWithErrors) > 0 || len(r.Failed()) > 0 {
+		return false
+	}
+	for _, fix := range r.FixtestResults {
+		if !fix.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// difference returns the items in a that are not in b, sorted.
+func difference(a, b []int) []int {
+	inB := make(map[int]bool, len(b))
+	for _, n := range b {
+		inB[n] = true
+	}
+	var diff []int
+	for _, n := range a {
+		if !inB[n] {
+			diff = append(diff, n)
+		}
+	}
+	sort.Ints(diff)
+	return diff
+}
+
+// ----------
+
+// TestRules runs `semgrep --test` with the rules in o.Rules. The test targets
+// are next to the rules or in o.Paths. Output, Verbosity, and the typed scan
+// switches are ignored, Extra is passed as-is.
+//
+// Failing tests are not an error, check TestResults.Passed or
+// TestResults.Failed. The error is set if Semgrep couldn't run the tests.
+func TestRules(ctx context.Context, o *Options) (TestResults, error) {
+	var res TestResults
+
+	// Store string rules in a temp directory.
+	ruleFile, cleanup, err := o.writeRules()
+	if err != nil {
+		return res, err
+	}
+	defer cleanup()
+
+	metrics := MetricsOff
+	if o.metrics {
+		metrics = MetricsOn
+	}
+	opts := []string{TestSwitch, JSON.String(), metrics}
+	opts = append(opts, o.configSwitches(ruleFile)...)
+	opts = append(opts, o.Extra...)
+	opts = append(opts, o.Paths...)
+
+	stdout, err := o.execute(ctx, opts)
+	if err != nil {
+		// Semgrep exits with 1 when tests fail, the results are in stdout.
+		if !errors.Is(err, ReasonFindings) {
+			return res, err
+		}
+	}
+
+	if err := json.Unmarshal(stdout, &res); err != nil {
+		return res, fmt.Errorf("failed to deserialize the test results: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,90 @@
+package run_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/run"
+	"github.com/parsiya/semgrep_go/run/runtest"
+)
+
+const testResultsJSON = `{
+  "config_missing_tests": ["rules/no-tests.yaml"],
+  "config_missing_fixtests": [],
+  "config_with_errors": [],
+  "results": {
+    "rules/exec.yaml": {
+      "checks": {
+        "go.exec-injection": {
+          "passed": false,
+          "matches": {
+            "rules/exec.go": {"expected_lines": [5, 10, 15], "reported_lines": [5, 12, 15]}
+          },
+          "errors": []
+        },
+        "go.exec-unsafe": {
+          "passed": true,
+          "matches": {
+            "rules/exec.go": {"expected_lines": [20], "reported_lines": [20]}
+          },
+          "errors": []
+        }
+      }
+    }
+  },
+  "fixtest_results": {}
+}`
+
+func TestTestRules(t *testing.T) {
+	// Semgrep exits with 1 when a test fails.
+	fake := runtest.New(
+		runtest.JSON([]byte(testResultsJSON)).WithExitCode(1).
+			Expect("semgrep", "--test", "--json", "--metrics=off", "--config", "rules/", "rules/"),
+	)
+	opts := &run.Options{Rules: []string{"rules/"}, Paths: []string{"rules/"}, Runner: fake}
+
+	res, err := run.TestRules(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("TestRules() error = %v", err)
+	}
+	fake.Check(t)
+
+	if res.Passed() {
+		t.Errorf("TestResults.Passed() = true, want false")
+	}
+	if len(res.Rules()) != 2 {
+		t.Fatalf("len(TestResults.Rules()) = %d, want %d", len(res.Rules()), 2)
+	}
+
+	failed := res.Failed()
+	want := []run.RuleTest{
+		{
+			RuleID:   "go.exec-injection",
+			RuleFile: "rules/exec.yaml",
+			Passed:   false,
+			Targets: []run.TargetTest{
+				{
+					Path:       "rules/exec.go",
+					Expected:   []int{5, 10, 15},
+					Reported:   []int{5, 12, 15},
+					Missing:    []int{10},
+					Unexpected: []int{12},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(failed, want) {
+		t.Errorf("TestResults.Failed() = %+v, want %+v", failed, want)
+	}
+}
+
+func TestTestRules_Error(t *testing.T) {
+	fake := runtest.New(runtest.Call{}.WithExitCode(7).WithStderr("invalid config"))
+	opts := &run.Options{Rules: []string{"missing/"}, Runner: fake}
+
+	if _, err := run.TestRules(context.Background(), opts); !errors.Is(err, run.ReasonMissingConfig) {
+		t.Errorf("TestRules() error = %v, want %v", err, run.ReasonMissingConfig)
+	}
+}
@@ -2,10 +2,14 @@ package run
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os/exec"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/parsiya/semgrep_go/output"
@@ -25,6 +29,24 @@ const (
 	// Metrics on and off.
 	MetricsOn  = "--metrics=on"
 	MetricsOff = "--metrics=off"
+
+	// Switches for the typed fields in Options.
+	IncludeSwitch              = "--include"
+	ExcludeSwitch              = "--exclude"
+	JobsSwitch                 = "--jobs"
+	TimeoutSwitch              = "--timeout"
+	TimeoutThresholdSwitch     = "--timeout-threshold"
+	MaxMemorySwitch            = "--max-memory"
+	MaxTargetBytesSwitch       = "--max-target-bytes"
+	SeveritySwitch             = "--severity"
+	ExcludeRuleSwitch          = "--exclude-rule"
+	BaselineCommitSwitch       = "--baseline-commit"
+	AutofixSwitch              = "--autofix"
+	DryRunSwitch               = "--dryrun"
+	NoGitIgnoreSwitch          = "--no-git-ignore"
+	TimeSwitch                 = "--time"
+	MatchingExplanationsSwitch = "--matching-explanations"
+	DataflowTracesSwitch       = "--dataflow-traces"
 )
 
 // OutputFormat enums.
@@ -64,6 +86,17 @@ func (v Verbosity) String() string {
 
 // ----------
 
+// Severity enums for the `--severity` switch.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "INFO"
+	SeverityWarning Severity = "WARNING"
+	SeverityError   Severity = "ERROR"
+)
+
+// ----------
+
 // Semgrep CLI switches.
 type Options struct {
 
@@ -91,15 +124,119 @@ type Options struct {
 	// rulesets (e.g., auto), or a URI with the ruleset.
 	//
 	// If true, it's assumed the strings in Rules contains one or more Semgrep
-	// rules. The contents will be concatenated and stored in a temp file and
-	// the name will be passed to Semgrep with `--config`. This is useful when
-	// we are using dynamicly created rules.
+	// rules. The rules are merged into one rule file in a private temp
+	// directory and the file is passed to Semgrep with `--config`. This is
+	// useful when we are using dynamicly created rules.
+	//
+	// Each string can be a rule file with a `rules` key, a single rule, or
+	// multiple YAML documents separated by `---`.
 	//
 	// You can set it to true by calling StringRule().
 	stringRule bool
 
-	// Extra switches. The user is responsible for their validity.
+	// If true, the temp directory with the string rules is not deleted after
+	// the run. The path is logged. Useful for debugging rules.
+	KeepTempFiles bool
+
+	// Only scan files that match these glob patterns. `--include`.
+	Include []string
+
+	// Skip files that match these glob patterns. `--exclude`.
+	Exclude []string
+
+	// Number of subprocesses to use. Zero uses Semgrep's default. `--jobs`.
+	Jobs int
+
+	// Maximum time in seconds to spend running a rule on a single file. Zero
+	// uses Semgrep's default. `--timeout`.
+	Timeout int
+
+	// Maximum number of rules that can time out on a file before the file is
+	// skipped. Zero uses Semgrep's default. `--timeout-threshold`.
+	TimeoutThreshold int
+
+	// Maximum memory in MiB to use when running a rule on a single file. Zero
+	// uses Semgrep's default. `--max-memory`.
+	MaxMemory int
+
+	// Maximum size of a file in bytes. Larger files are skipped. Zero uses
+	// Semgrep's default. `--max-target-bytes`.
+	MaxTargetBytes int
+
+	// Only report findings with these severities. `--severity`.
+	Severity []Severity
+
+	// Skip rules with these IDs. `--exclude-rule`.
+	ExcludeRule []string
+
+	// Only report findings that were introduced after this git commit.
+	// `--baseline-commit`.
+	BaselineCommit string
+
+	// Apply the fixes in the rules to the files. `--autofix`.
+	Autofix bool
+
+	// Don't modify the files when Autofix is true, only report the fixes.
+	// Requires Autofix. `--dryrun`.
+	DryRun bool
+
+	// Scan files ignored by git. `--no-git-ignore`.
+	NoGitIgnore bool
+
+	// Include the timing summary in the output. `--time`.
+	Time bool
+
+	// Add debugging information about how the rules matched to the JSON
+	// output. `--matching-explanations`.
+	MatchingExplanations bool
+
+	// Add the dataflow traces of taint findings to the output.
+	// `--dataflow-traces`.
+	DataflowTraces bool
+
+	// Extra switches. The user is responsible for their validity. Validate
+	// returns an error if they conflict with the typed fields.
 	Extra []string
+
+	// If set, everything Semgrep prints to stderr is also written here while
+	// Semgrep is running. E.g., os.Stderr to see the logs live.
+	LogWriter io.Writer
+
+	// If set, called with every line Semgrep prints to stderr while it's
+	// running.
+	OnLog func(line string)
+
+	// If set, called with the progress events parsed from Semgrep's stderr.
+	// See ParseProgress. Use Verbose or Debug verbosity to get more events.
+	//
+	// OnLog and OnProgress are called sequentially from a single goroutine.
+	// Semgrep is blocked until they return so keep them fast.
+	OnProgress func(Progress)
+
+	// Executes Semgrep. If nil, ExecRunner is used.
+	Runner Runner
+
+	// What to do when the Semgrep version is outside the range the output
+	// structs were tested with. The default is IgnoreVersion.
+	VersionPolicy VersionPolicy
+
+	// The command that runs Semgrep. The first item is the binary and the rest
+	// are passed before the switches. E.g., `/opt/semgrep-1.52.0/bin/semgrep`
+	// or `python3 -m semgrep`. If empty, Semgrep is used.
+	Command []string
+
+	// The working directory. If empty, the current directory is used. Relative
+	// paths in Rules and Paths are relative to this directory.
+	Dir string
+
+	// Environment variables for Semgrep. They override the inherited ones.
+	// E.g., SEMGREP_APP_TOKEN or SEMGREP_SEND_METRICS.
+	Env map[string]string
+
+	// If not nil, only these variables are inherited from the current
+	// environment. Use an empty slice to inherit nothing. Semgrep usually needs
+	// at least PATH and HOME. Env is always added.
+	InheritEnv []string
 }
 
 // Return a new Options struct.
@@ -117,36 +254,16 @@ func (s *Options) EnableMetrics() {
 	s.metrics = true
 }
 
-// Concat all the rules together in a temp file and pass to Semgrep.
+// Merge all the rules together in a temp file and pass to Semgrep.
 func (s *Options) StringRule() {
 	s.stringRule = true
 }
 
 // Return the options as a string array that can be passed to os/exec.Command.
-func (o *Options) string() ([]string, error) {
-	var optStr []string
-
-	// If stringRule is true, store the rules in a temp file.
-	if o.stringRule {
-		var err error
-		// Concatenate all strings in rules together.
-		mergedRules := ""
-		for _, r := range o.Rules {
-			mergedRules += r
-		}
-		// Store them all in a temp file.
-		ruleFile, err := createTempFile(mergedRules)
-		if err != nil {
-			return nil, err
-		}
-		// Add the rules string to the options string.
-		optStr = append(optStr, ConfigSwitch, ruleFile)
-	} else {
-		// Else, each item in o.Rules must be passed with `--config`.
-		for _, r := range o.Rules {
-			optStr = append(optStr, ConfigSwitch, r)
-		}
-	}
+// ruleFile is the merged rule file if stringRule is true.
+func (o *Options) string(ruleFile string) ([]string, error) {
+	// Add the rules.
+	optStr := o.configSwitches(ruleFile)
 
 	// Add metrics.
 	metrics := MetricsOff
@@ -169,6 +286,9 @@ func (o *Options) string() ([]string, error) {
 	}
 	optStr = append(optStr, o.Verbosity.String())
 
+	// Add the typed switches.
+	optStr = append(optStr, o.typedSwitches()...)
+
 	// Add any extra switches.
 	optStr = append(optStr, o.Extra...)
 
@@ -178,29 +298,296 @@ func (o *Options) string() ([]string, error) {
 	return optStr, nil
 }
 
+// typedSwitches returns the switches for the typed fields.
+func (o *Options) typedSwitches() []string {
+	var optStr []string
+
+	// Add a switch for every item in the slice.
+	addEach := func(sw string, values []string) {
+		for _, v := range values {
+			optStr = append(optStr, sw, v)
+		}
+	}
+	// Add a switch if the number is set.
+	addInt := func(sw string, value int) {
+		if value > 0 {
+			optStr = append(optStr, sw, strconv.Itoa(value))
+		}
+	}
+	// Add a switch without a value if the flag is set.
+	addBool := func(sw string, value bool) {
+		if value {
+			optStr = append(optStr, sw)
+		}
+	}
+
+	addEach(IncludeSwitch, o.Include)
+	addEach(ExcludeSwitch, o.Exclude)
+	addInt(JobsSwitch, o.Jobs)
+	addInt(TimeoutSwitch, o.Timeout)
+	addInt(TimeoutThresholdSwitch, o.TimeoutThreshold)
+	addInt(MaxMemorySwitch, o.MaxMemory)
+	addInt(MaxTargetBytesSwitch, o.MaxTargetBytes)
+	for _, sev := range o.Severity {
+		optStr = append(optStr, SeveritySwitch, string(sev))
+	}
+	addEach(ExcludeRuleSwitch, o.ExcludeRule)
+	if o.BaselineCommit != "" {
+		optStr = append(optStr, BaselineCommitSwitch, o.BaselineCommit)
+	}
+	addBool(AutofixSwitch, o.Autofix)
+	addBool(DryRunSwitch, o.DryRun)
+	addBool(NoGitIgnoreSwitch, o.NoGitIgnore)
+	addBool(TimeSwitch, o.Time)
+	addBool(MatchingExplanationsSwitch, o.MatchingExplanations)
+	addBool(DataflowTracesSwitch, o.DataflowTraces)
+
+	return optStr
+}
+
+// Validate checks the options for invalid values and conflicts before Semgrep
+// is started. The returned error wraps ErrInvalidOptions.
+func (o *Options) Validate() error {
+	var problems []string
+
+	// Numbers can't be negative.
+	numbers := []struct {
+		name  string
+		value int
+	}{
+		{"Jobs", o.Jobs},
+		{"Timeout", o.Timeout},
+		{"TimeoutThreshold", o.TimeoutThreshold},
+		{"MaxMemory", o.MaxMemory},
+		{"MaxTargetBytes", o.MaxTargetBytes},
+	}
+	for _, n := range numbers {
+		if n.value < 0 {
+			problems = append(problems, fmt.Sprintf("%s cannot be negative: %d", n.name, n.value))
+		}
+	}
+
+	// Patterns and rule IDs can't be empty.
+	lists := []struct {
+		name   string
+		values []string
+	}{
+		{"Include", o.Include},
+		{"Exclude", o.Exclude},
+		{"ExcludeRule", o.ExcludeRule},
+	}
+	for _, l := range lists {
+		for _, v := range l.values {
+			if strings.TrimSpace(v) == "" {
+				problems = append(problems, fmt.Sprintf("%s has an empty value", l.name))
+				break
+			}
+		}
+	}
+
+	for _, sev := range o.Severity {
+		switch sev {
+		case SeverityInfo, SeverityWarning, SeverityError:
+		default:
+			problems = append(problems, fmt.Sprintf("invalid Severity: %q", sev))
+		}
+	}
+
+	if o.DryRun && !o.Autofix {
+		problems = append(problems, "DryRun requires Autofix")
+	}
+
+	// Extra cannot set a switch that is controlled by a typed field.
+	typed := make(map[string]bool)
+	for _, sw := range o.typedSwitches() {
+		if strings.HasPrefix(sw, "--") {
+			typed[sw] = true
+		}
+	}
+	// Output format and verbosity are always set.
+	controlled := map[string]string{
+		Text.String(): "Output", Emacs.String(): "Output", JSON.String(): "Output",
+		GitLabSAST.String(): "Output", GitLabSecrets.String(): "Output",
+		JUnitXML.String(): "Output", SARIF.String(): "Output", Vim.String(): "Output",
+		Quiet.String(): "Verbosity", Verbose.String(): "Verbosity", Debug.String(): "Verbosity",
+	}
+	for _, e := range o.Extra {
+		// Remove the value from `--switch=value`.
+		sw := strings.SplitN(e, "=", 2)[0]
+		if field, exists := controlled[sw]; exists {
+			problems = append(problems, fmt.Sprintf("Extra switch %s conflicts with %s", e, field))
+		}
+		if typed[sw] {
+			problems = append(problems, fmt.Sprintf("Extra switch %s is already set by a typed field", e))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// stderrLines returns a lineWriter that calls OnLog and OnProgress. Returns nil
+// if neither is set.
+func (o *Options) stderrLines() *lineWriter {
+	if o.OnLog == nil && o.OnProgress == nil {
+		return nil
+	}
+	return &lineWriter{fn: func(line string) {
+		if o.OnLog != nil {
+			o.OnLog(line)
+		}
+		if o.OnProgress != nil {
+			if p, ok := ParseProgress(line); ok {
+				o.OnProgress(p)
+			}
+		}
+	}}
+}
+
 // ----------
 
-// Run Semgrep and return the results without deserializing.
-func internalRun(o *Options) ([]byte, error) {
+// Run Semgrep and return the results without deserializing. If the context is
+// canceled or its deadline passes, the Semgrep process and all of its children
+// are killed and a *ContextError with the partial output is returned. If stdout
+// is not nil, Semgrep's stdout is written to it instead of being returned.
+func internalRun(ctx context.Context, o *Options, stdout io.Writer) ([]byte, error) {
+	// Don't start Semgrep if the context is already done.
+	if err := ctx.Err(); err != nil {
+		return nil, &ContextError{Err: err}
+	}
+
+	// Check the options before starting Semgrep.
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Check the Semgrep version before the scan.
+	if o.VersionPolicy == RefuseVersion {
+		if err := o.checkVersion(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// If stringRule is true, store the rules in a temp directory. It's deleted
+	// when we return, even if Semgrep panics or is canceled.
+	ruleFile, cleanup, err := o.writeRules()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
 	// Convert the switches to a string.
-	opts, err := o.string()
+	opts, err := o.string(ruleFile)
 	if err != nil {
 		return nil, err
 	}
-	// Add them to the command.
-	cmd := exec.Command(Semgrep, opts...)
+	return o.executeTo(ctx, opts, stdout)
+}
 
-	log.Printf("Running Semgrep as: %s", cmd.String())
+// writeRules merges the string rules and writes them to a new temp directory.
+// It returns the path to the rule file and a function that deletes the
+// directory. If stringRule is false, it does nothing.
+func (o *Options) writeRules() (ruleFile string, cleanup func(), err error) {
+	cleanup = func() {}
+	if !o.stringRule {
+		return "", cleanup, nil
+	}
+
+	merged, err := mergeRules(o.Rules)
+	if err != nil {
+		return "", cleanup, err
+	}
+	dir, err := createTempDir()
+	if err != nil {
+		return "", cleanup, fmt.Errorf("couldn't create the temp directory: %w", err)
+	}
+	cleanup = func() { removeTempDir(dir, o.KeepTempFiles) }
+
+	ruleFile, err = writeTempFile(dir, ruleFileName, merged)
+	if err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("couldn't write the rules to the temp directory: %w", err)
+	}
+	return ruleFile, cleanup, nil
+}
+
+// configSwitches returns the `--config` switches for the rules. ruleFile is the
+// merged rule file if stringRule is true.
+func (o *Options) configSwitches(ruleFile string) []string {
+	var optStr []string
+	// If stringRule is true, pass the merged rule file.
+	if o.stringRule {
+		return append(optStr, ConfigSwitch, ruleFile)
+	}
+	// Else, each item in o.Rules must be passed with `--config`.
+	for _, r := range o.Rules {
+		optStr = append(optStr, ConfigSwitch, r)
+	}
+	return optStr
+}
+
+// execute runs Semgrep with the switches and returns stdout. If Semgrep cannot
+// be started or exits with a non-zero exit code, the error is an *ExecError.
+func (o *Options) execute(ctx context.Context, opts []string) ([]byte, error) {
+	return o.executeTo(ctx, opts, nil)
+}
+
+// executeTo is execute with an optional writer for stdout. If stdout is not
+// nil, Semgrep's stdout is only written there. The returned bytes and the
+// stdout in the errors are empty.
+func (o *Options) executeTo(ctx context.Context, opts []string, stdout io.Writer) ([]byte, error) {
+	// Add the switches to the command.
+	args := append(o.command(), opts...)
+
+	log.Printf("Running Semgrep as: %s", strings.Join(args, " "))
 
 	// Set stdout and stderr.
 	var stdOut, stdErr bytes.Buffer
-	cmd.Stdout = &stdOut
-	cmd.Stderr = &stdErr
+	inv := &Invocation{
+		Args:   args,
+		Dir:    o.Dir,
+		Env:    o.environ(),
+		Stdout: &stdOut,
+		Stderr: &stdErr,
+	}
+	if stdout != nil {
+		inv.Stdout = stdout
+	}
+
+	// Stream stderr to the log writer and callbacks.
+	lines := o.stderrLines()
+	if o.LogWriter != nil || lines != nil {
+		writers := []io.Writer{&stdErr}
+		if o.LogWriter != nil {
+			writers = append(writers, o.LogWriter)
+		}
+		if lines != nil {
+			writers = append(writers, lines)
+			defer lines.Flush()
+		}
+		inv.Stderr = io.MultiWriter(writers...)
+	}
 
 	// Run Semgrep.
-	if err := cmd.Run(); err != nil {
-		// Return the error.
-		return nil, errors.New(stdErr.String())
+	exitCode, err := o.runner().Run(ctx, inv)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Semgrep was killed because the context is done.
+			return stdOut.Bytes(), &ContextError{
+				Err:    ctx.Err(),
+				Stdout: stdOut.Bytes(),
+				Stderr: stdErr.String(),
+			}
+		}
+		// Semgrep didn't start.
+		return nil, newExecError(args, -1, err, nil, "")
+	}
+	if exitCode != 0 {
+		// Semgrep prints the JSON output (including the errors) even when it
+		// fails, so we return stdout alongside the error.
+		return stdOut.Bytes(), newExecError(args, exitCode, nil, stdOut.Bytes(), stdErr.String())
 	}
 
 	// If Semgrep started successfully but has an internal error, stderr will be
@@ -208,6 +595,58 @@ func internalRun(o *Options) ([]byte, error) {
 	return stdOut.Bytes(), nil
 }
 
+// command returns a copy of the Semgrep command. Defaults to Semgrep.
+func (o *Options) command() []string {
+	if len(o.Command) == 0 {
+		return []string{Semgrep}
+	}
+	return append([]string(nil), o.Command...)
+}
+
+// environ returns the environment for Semgrep. Returns nil if the current
+// environment should be inherited as-is.
+func (o *Options) environ() []string {
+	if o.Env == nil && o.InheritEnv == nil {
+		return nil
+	}
+
+	var env []string
+	if o.InheritEnv == nil {
+		env = os.Environ()
+	} else {
+		// Only inherit the allowed variables.
+		for _, name := range o.InheritEnv {
+			if val, exists := os.LookupEnv(name); exists {
+				env = append(env, name+"="+val)
+			}
+		}
+	}
+
+	// Add the overrides in a stable order. os/exec uses the last value if a
+	// key is duplicated.
+	keys := make([]string, 0, len(o.Env))
+	for k := range o.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+o.Env[k])
+	}
+	// Return an empty (not nil) environment if nothing was added.
+	if env == nil {
+		env = []string{}
+	}
+	return env
+}
+
+// runner returns the Runner. Defaults to ExecRunner.
+func (o *Options) runner() Runner {
+	if o.Runner != nil {
+		return o.Runner
+	}
+	return ExecRunner{}
+}
+
 // Run Semgrep and return output as-is.
 //
 // Note that just because the Semgrep command was executed, doesn't mean there
@@ -216,39 +655,85 @@ func internalRun(o *Options) ([]byte, error) {
 //
 // We will return them as-is and let the user decide what they want to do with
 // the errors.
+//
+// If Semgrep exits with a non-zero exit code, the error is an *ExecError and
+// the returned bytes contain whatever Semgrep printed to stdout.
 func (o *Options) Run() ([]byte, error) {
+	return o.RunContext(context.Background())
+}
+
+// RunContext is Run with a context. If the context is canceled or its deadline
+// passes, Semgrep and all of its child processes are killed. The returned error
+// will be a *ContextError and the returned bytes will contain whatever Semgrep
+// wrote to stdout before it was killed.
+func (o *Options) RunContext(ctx context.Context) ([]byte, error) {
 	// Run Semgrep and return any errors if Semgrep did not run.
-	return internalRun(o)
+	return internalRun(ctx, o, nil)
 }
 
 // Run Semgrep and return the deserialized JSON output.
+//
+// If Semgrep exits with a non-zero exit code but still prints the JSON output
+// (e.g., findings with `--error`), the deserialized output is returned together
+// with the *ExecError.
 func (o *Options) RunJSON() (out output.Output, err error) {
+	return o.RunJSONContext(context.Background())
+}
+
+// RunJSONContext is RunJSON with a context. If the context is canceled or its
+// deadline passes, Semgrep and all of its child processes are killed and a
+// *ContextError is returned. Semgrep only prints the JSON output at the end of
+// the scan so the partial stdout in the error is usually empty.
+func (o *Options) RunJSONContext(ctx context.Context) (out output.Output, err error) {
 	// Change the output format to JSON in case we made a mistake when creating
 	// the Options object.
 	o.Output = JSON
 
 	// Run Semgrep and return any errors if Semgrep did not run.
-	data, err := internalRun(o)
+	data, err := internalRun(ctx, o, nil)
 	if err != nil {
+		// Semgrep ran but exited with an error. Return the output if it's
+		// there.
+		var execErr *ExecError
+		if errors.As(err, &execErr) && len(data) > 0 {
+			if out, desErr := o.deserialize(data); desErr == nil {
+				return out, err
+			}
+		}
 		return out, err
 	}
 	// Deserialize the output.
-	return output.Deserialize(data)
+	return o.deserialize(data)
 }
 
-// Checks if Semgrep is installed.
+// Checks if Semgrep is installed. Use Options.IsInstalled to check a specific
+// binary, working directory, and environment.
 func IsInstalled() bool {
-	_, err := Version()
-	return err == nil
+	return (&Options{}).IsInstalled()
 }
 
 // Returns the Semgrep version.
 func Version() (string, error) {
-	cmd := exec.Command(Semgrep, VersionSwitch)
-	var stdout strings.Builder
-	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("error running semgrep: %v", err)
+	return (&Options{}).Version()
+}
+
+// IsInstalled checks if Semgrep can be executed with these options. It
+// respects Command, Dir, Env, InheritEnv, and Runner.
+func (o *Options) IsInstalled() bool {
+	_, err := o.Version()
+	return err == nil
+}
+
+// Version returns the version of Semgrep executed with these options.
+func (o *Options) Version() (string, error) {
+	return o.version(context.Background())
+}
+
+// version runs `semgrep --version` with a context.
+func (o *Options) version(ctx context.Context) (string, error) {
+	stdout, err := o.execute(ctx, []string{VersionSwitch})
+	if err != nil {
+		return "", fmt.Errorf("error running semgrep: %w", err)
 	}
-	return strings.TrimSpace(stdout.String()), nil
+	return strings.TrimSpace(string(stdout)), nil
 }
@@ -1,7 +1,17 @@
 package run
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestOutputFormat_String(t *testing.T) {
@@ -38,20 +48,282 @@ func TestOutputFormat_String(t *testing.T) {
 	}
 }
 
-func TestIsInstalled(t *testing.T) {
+// fakeSemgrep creates an executable shell script named semgrep in a temp
+// directory and puts it at the start of PATH.
+func fakeSemgrep(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake semgrep script needs a Unix shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, Semgrep)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestOptions_RunContext(t *testing.T) {
+	// Print something, then start a child that outlives the parent's deadline.
+	fakeSemgrep(t, "echo partial\nsleep 30 &\nsleep 30\n")
+
+	tests := []struct {
+		name        string
+		ctx         func() (context.Context, context.CancelFunc)
+		wantTimeout bool
+		wantErr     error
+	}{
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 500*time.Millisecond)
+			},
+			wantTimeout: true,
+			wantErr:     context.DeadlineExceeded,
+		},
+		{
+			name: "cancel",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(500*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantTimeout: false,
+			wantErr:     context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			opts := DefaultOptions([]string{"p/default"}, []string{"."})
+			got, err := opts.RunContext(ctx)
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Fatalf("RunContext() returned after %v, the process tree was not killed", elapsed)
+			}
+
+			var ctxErr *ContextError
+			if !errors.As(err, &ctxErr) {
+				t.Fatalf("RunContext() error = %v, want *ContextError", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("RunContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if ctxErr.Timeout() != tt.wantTimeout {
+				t.Errorf("ContextError.Timeout() = %v, want %v", ctxErr.Timeout(), tt.wantTimeout)
+			}
+			if string(got) != "partial\n" {
+				t.Errorf("RunContext() partial stdout = %q, want %q", got, "partial\n")
+			}
+		})
+	}
+}
+
+func TestOptions_RunContext_DetachedChild(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid is not installed")
+	}
+	// The child leaves the process group and holds stdout open after the
+	// group is killed.
+	fakeSemgrep(t, "echo partial\nsetsid sleep 30 &\nsleep 30\n")
+
+	defer func(d time.Duration) { WaitDelay = d }(WaitDelay)
+	WaitDelay = 200 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := DefaultOptions([]string{"p/default"}, []string{"."}).RunContext(ctx)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("RunContext() returned after %v, the pipes were not closed", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestOptions_Run_ProcessGroup(t *testing.T) {
+	if _, err := exec.LookPath("ps"); err != nil {
+		t.Skip("ps is not installed")
+	}
+	// Print the process group of the fake Semgrep.
+	fakeSemgrep(t, "ps -o pgid= -p $$ >&2\n")
+	ours, err := exec.Command("ps", "-o", "pgid=", "-p", strconv.Itoa(os.Getpid())).Output()
+	if err != nil {
+		t.Skip("ps doesn't support -o pgid")
+	}
+
+	var stderr strings.Builder
+	opts := DefaultOptions([]string{"p/default"}, []string{"."})
+	opts.LogWriter = &stderr
+	if _, err := opts.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(stderr.String()); got != strings.TrimSpace(string(ours)) {
+		t.Errorf("Run() process group = %s, want ours (%s)", got, strings.TrimSpace(string(ours)))
+	}
+
+	stderr.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := opts.RunContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(stderr.String()); got == strings.TrimSpace(string(ours)) {
+		t.Errorf("RunContext() with a cancelable context used our process group")
+	}
+}
+
+func TestOptions_Run_CommandDirEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake semgrep script needs a Unix shell")
+	}
+	// A fake Semgrep that is not on PATH. It prints the working directory, an
+	// overridden variable, and the first switch.
+	script := filepath.Join(t.TempDir(), "fake-semgrep.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$PWD $SEMGREP_SEND_METRICS $1\"\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	opts := DefaultOptions([]string{"rules.yaml"}, []string{"."})
+	opts.Command = []string{"/bin/sh", script}
+	opts.Dir = dir
+	opts.Env = map[string]string{"SEMGREP_SEND_METRICS": "off"}
+
+	got, err := opts.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// $PWD might be a symlink, compare the resolved paths.
+	fields := strings.Fields(string(got))
+	if len(fields) != 3 {
+		t.Fatalf("Run() = %q", got)
+	}
+	gotDir, _ := filepath.EvalSymlinks(fields[0])
+	wantDir, _ := filepath.EvalSymlinks(dir)
+	if gotDir != wantDir || fields[1] != "off" || fields[2] != "--config" {
+		t.Errorf("Run() = %q, want %q", got, wantDir+" off --config")
+	}
+}
+
+func TestOptions_string_TypedSwitches(t *testing.T) {
+	opts := DefaultOptions([]string{"p/default"}, []string{"src"})
+	opts.Include = []string{"*.go"}
+	opts.Exclude = []string{"vendor", "*_test.go"}
+	opts.Jobs = 4
+	opts.Timeout = 30
+	opts.TimeoutThreshold = 3
+	opts.MaxMemory = 2048
+	opts.MaxTargetBytes = 1000000
+	opts.Severity = []Severity{SeverityWarning, SeverityError}
+	opts.ExcludeRule = []string{"go.lang.security.audit.xss"}
+	opts.BaselineCommit = "abc123"
+	opts.Autofix = true
+	opts.DryRun = true
+	opts.NoGitIgnore = true
+	opts.Time = true
+	opts.MatchingExplanations = true
+	opts.DataflowTraces = true
+	opts.Extra = []string{"--strict"}
+
+	got, err := opts.string("")
+	if err != nil {
+		t.Fatalf("Options.string() error = %v", err)
+	}
+	want := []string{
+		"--config", "p/default", "--metrics=off", "--json", "--debug",
+		"--include", "*.go",
+		"--exclude", "vendor", "--exclude", "*_test.go",
+		"--jobs", "4",
+		"--timeout", "30",
+		"--timeout-threshold", "3",
+		"--max-memory", "2048",
+		"--max-target-bytes", "1000000",
+		"--severity", "WARNING", "--severity", "ERROR",
+		"--exclude-rule", "go.lang.security.audit.xss",
+		"--baseline-commit", "abc123",
+		"--autofix", "--dryrun", "--no-git-ignore", "--time",
+		"--matching-explanations", "--dataflow-traces",
+		"--strict",
+		"src",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Options.string() =\n%q\nwant\n%q", got, want)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Errorf("Options.Validate() error = %v", err)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
 	tests := []struct {
-		name string
-		want bool
+		name    string
+		modify  func(o *Options)
+		wantErr bool
 	}{
 		{
-			name: "installed",
-			want: true,
+			name:    "default",
+			modify:  func(o *Options) {},
+			wantErr: false,
+		},
+		{
+			name:    "negative jobs",
+			modify:  func(o *Options) { o.Jobs = -1 },
+			wantErr: true,
+		},
+		{
+			name:    "negative timeout",
+			modify:  func(o *Options) { o.Timeout = -5 },
+			wantErr: true,
+		},
+		{
+			name:    "invalid severity",
+			modify:  func(o *Options) { o.Severity = []Severity{"CRITICAL"} },
+			wantErr: true,
+		},
+		{
+			name:    "empty exclude",
+			modify:  func(o *Options) { o.Exclude = []string{" "} },
+			wantErr: true,
+		},
+		{
+			name:    "dryrun without autofix",
+			modify:  func(o *Options) { o.DryRun = true },
+			wantErr: true,
+		},
+		{
+			name: "extra conflicts with typed field",
+			modify: func(o *Options) {
+				o.Jobs = 2
+				o.Extra = []string{"--jobs=4"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "extra sets an unused typed switch",
+			modify:  func(o *Options) { o.Extra = []string{"--jobs", "4"} },
+			wantErr: false,
+		},
+		{
+			name:    "extra conflicts with output",
+			modify:  func(o *Options) { o.Extra = []string{"--sarif"} },
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := IsInstalled(); got != tt.want {
-				t.Errorf("IsInstalled() = %v, want %v", got, tt.want)
+			opts := DefaultOptions([]string{"p/default"}, []string{"."})
+			tt.modify(opts)
+			err := opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Options.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("Options.Validate() error = %v, want %v", err, ErrInvalidOptions)
 			}
 		})
 	}
@@ -0,0 +1,111 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os/exec"
+	"time"
+)
+
+// WaitDelay is how long ExecRunner waits for Semgrep's stdout and stderr to be
+// closed after it exits. A child that leaves the process group (e.g., a daemon)
+// can keep them open. After this delay, the pipes are closed and Run returns
+// even if the child is still running.
+var WaitDelay = 5 * time.Second
+
+// Invocation is a single execution of Semgrep.
+type Invocation struct {
+	// The argv. Args[0] is the Semgrep binary.
+	Args []string
+
+	// The working directory. If empty, the current directory is used.
+	Dir string
+
+	// The environment in "key=value" form. If nil, the current environment is
+	// inherited.
+	Env []string
+
+	// Where the runner should write Semgrep's stdout and stderr.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runner executes Semgrep. Options uses ExecRunner by default. Implement this
+// interface to run Semgrep somewhere else (e.g., in a container) or to fake it
+// in tests. See the runtest package.
+type Runner interface {
+	// Run executes Semgrep and returns its exit code. A non-zero exit code is
+	// not an error. The error must only be set if Semgrep couldn't be started
+	// or was stopped because the context is done.
+	Run(ctx context.Context, inv *Invocation) (exitCode int, err error)
+}
+
+// ExecRunner runs Semgrep as a local process with os/exec.
+//
+// If the context can be canceled, Semgrep runs in its own process group and
+// all of its child processes are killed when the context is done. The group is
+// not in the terminal's foreground, so Ctrl-C doesn't reach Semgrep directly.
+// On Linux, Semgrep is terminated if the Go program dies. On other systems,
+// stop Semgrep by canceling the context (e.g., with signal.NotifyContext).
+//
+// Without a cancelable context (e.g., Options.Run), Semgrep stays in the
+// caller's process group and gets Ctrl-C like any other child.
+type ExecRunner struct{}
+
+// Run executes Semgrep.
+func (ExecRunner) Run(ctx context.Context, inv *Invocation) (int, error) {
+	if len(inv.Args) == 0 {
+		return -1, errors.New("empty semgrep command")
+	}
+	cmd := exec.Command(inv.Args[0], inv.Args[1:]...)
+	cmd.Dir = inv.Dir
+	cmd.Env = inv.Env
+	cmd.Stdout = inv.Stdout
+	cmd.Stderr = inv.Stderr
+	cmd.WaitDelay = WaitDelay
+
+	// Run Semgrep in its own process group so we can kill the whole tree. Only
+	// do it if we might need to, see ExecRunner.
+	if ctx.Done() != nil {
+		setProcessGroup(cmd)
+	}
+
+	// Start Semgrep.
+	if err := cmd.Start(); err != nil {
+		return -1, err
+	}
+
+	// Wait for Semgrep in the background so we can also watch the context.
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		// A non-zero exit code is returned as *exec.ExitError, anything else
+		// means we couldn't read the output. exec.ErrWaitDelay means Semgrep
+		// exited but a child held the pipes open after WaitDelay.
+		if err != nil && !isExitError(err) && !errors.Is(err, exec.ErrWaitDelay) {
+			return -1, err
+		}
+		return cmd.ProcessState.ExitCode(), nil
+	case <-ctx.Done():
+		// Kill Semgrep and its children, then wait for the output to be
+		// flushed to the writers. WaitDelay makes sure this returns if a
+		// child outside the process group keeps the pipes open.
+		if err := killProcessTree(cmd); err != nil {
+			log.Printf("Couldn't kill the Semgrep process tree: %v", err)
+		}
+		<-done
+		return -1, ctx.Err()
+	}
+}
+
+// isExitError returns true if the error is an *exec.ExitError.
+func isExitError(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr)
+}
@@ -0,0 +1,132 @@
+package run_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parsiya/semgrep_go/run"
+	"github.com/parsiya/semgrep_go/run/runtest"
+)
+
+func TestIsInstalled(t *testing.T) {
+	tests := []struct {
+		name string
+		call runtest.Call
+		want bool
+	}{
+		{
+			name: "installed",
+			call: runtest.Version("1.52.0"),
+			want: true,
+		},
+		{
+			name: "not installed",
+			call: runtest.NotFound(),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runtest.New(tt.call)
+			opts := &run.Options{Runner: fake}
+			if got := opts.IsInstalled(); got != tt.want {
+				t.Errorf("IsInstalled() = %v, want %v", got, tt.want)
+			}
+			fake.Check(t)
+		})
+	}
+}
+
+func TestOptions_Version(t *testing.T) {
+	fake := runtest.New(runtest.Version("1.52.0"))
+	got, err := (&run.Options{Runner: fake}).Version()
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if got != "1.52.0" {
+		t.Errorf("Version() = %v, want %v", got, "1.52.0")
+	}
+	fake.Check(t)
+}
+
+func TestOptions_RunJSON_Fixture(t *testing.T) {
+	fake := runtest.New(
+		runtest.FromFile(t, "../output/test/juice-shop-1.42.0.json").
+			Expect("semgrep", "--config", "p/default", "--metrics=off", "--json", "--debug", "juice-shop"),
+	)
+	opts := run.DefaultOptions([]string{"p/default"}, []string{"juice-shop"})
+	opts.Runner = fake
+
+	out, err := opts.RunJSON()
+	if err != nil {
+		t.Fatalf("RunJSON() error = %v", err)
+	}
+	if len(out.Results) != 67 {
+		t.Errorf("len(RunJSON().Results) = %d, want %d", len(out.Results), 67)
+	}
+	fake.Check(t)
+}
+
+func TestOptions_Run_FakeExitCode(t *testing.T) {
+	fake := runtest.New(runtest.Call{}.WithExitCode(7).WithStderr("missing config"))
+	opts := run.DefaultOptions([]string{"missing.yaml"}, []string{"."})
+	opts.Runner = fake
+
+	_, err := opts.Run()
+	var execErr *run.ExecError
+	if !errors.As(err, &execErr) || !errors.Is(err, run.ReasonMissingConfig) {
+		t.Fatalf("Run() error = %v, want %v", err, run.ReasonMissingConfig)
+	}
+	if execErr.Stderr != "missing config" {
+		t.Errorf("ExecError.Stderr = %q, want %q", execErr.Stderr, "missing config")
+	}
+	fake.Check(t)
+}
+
+func TestOptions_RunContext_FakeHang(t *testing.T) {
+	fake := runtest.New(runtest.Call{Stdout: []byte("partial"), Hang: true})
+	opts := run.DefaultOptions([]string{"p/default"}, []string{"."})
+	opts.Runner = fake
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	got, err := opts.RunContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if string(got) != "partial" {
+		t.Errorf("RunContext() = %q, want %q", got, "partial")
+	}
+	fake.Check(t)
+}
+
+func TestOptions_CommandDirEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/semgrep")
+	t.Setenv("SEMGREP_APP_TOKEN", "secret")
+
+	fake := runtest.New(runtest.Version("1.52.0").Expect("python3", "-m", "semgrep", "--version"))
+	opts := &run.Options{
+		Runner:     fake,
+		Command:    []string{"python3", "-m", "semgrep"},
+		Dir:        "/src",
+		Env:        map[string]string{"SEMGREP_SEND_METRICS": "off"},
+		InheritEnv: []string{"HOME", "DOES_NOT_EXIST"},
+	}
+	if !opts.IsInstalled() {
+		t.Fatalf("IsInstalled() = false, want true")
+	}
+	fake.Check(t)
+
+	inv := fake.Invocations()[0]
+	if inv.Dir != "/src" {
+		t.Errorf("Invocation.Dir = %v, want %v", inv.Dir, "/src")
+	}
+	wantEnv := []string{"HOME=/home/semgrep", "SEMGREP_SEND_METRICS=off"}
+	if !reflect.DeepEqual(inv.Env, wantEnv) {
+		t.Errorf("Invocation.Env = %v, want %v", inv.Env, wantEnv)
+	}
+}
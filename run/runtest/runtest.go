@@ -0,0 +1,169 @@
+// Package runtest provides a fake Semgrep Runner for hermetic tests of code
+// that uses the run package.
+//
+//	fake := runtest.New(
+//		runtest.FromFile(t, "testdata/scan.json").
+//			Expect("semgrep", "--config", "p/default", "--metrics=off", "--json", "--debug", "."),
+//	)
+//	opts := run.DefaultOptions([]string{"p/default"}, []string{"."})
+//	opts.Runner = fake
+//	out, err := opts.RunJSON()
+//	fake.Check(t)
+package runtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/run"
+)
+
+// Call is a scripted Semgrep execution.
+type Call struct {
+	// The expected argv including the binary. If nil, the argv is not checked.
+	WantArgs []string
+
+	// What the fake Semgrep prints to stdout and stderr.
+	Stdout []byte
+	Stderr string
+
+	// The exit code.
+	ExitCode int
+
+	// Returned by the runner to simulate Semgrep not starting. E.g., see
+	// NotFound.
+	Err error
+
+	// If true, the fake Semgrep prints the output and then runs until the
+	// context is done. Simulates a long scan.
+	Hang bool
+}
+
+// Expect sets the expected argv and returns the call.
+func (c Call) Expect(args ...string) Call {
+	c.WantArgs = args
+	return c
+}
+
+// WithExitCode sets the exit code and returns the call.
+func (c Call) WithExitCode(code int) Call {
+	c.ExitCode = code
+	return c
+}
+
+// WithStderr sets stderr and returns the call.
+func (c Call) WithStderr(stderr string) Call {
+	c.Stderr = stderr
+	return c
+}
+
+// JSON returns a call that prints the data to stdout and exits with 0.
+func JSON(data []byte) Call {
+	return Call{Stdout: data}
+}
+
+// FromFile returns a call that prints a recorded Semgrep output (e.g.,
+// `output/test/juice-shop-1.42.0.json`) and exits with 0.
+func FromFile(t testing.TB, path string) Call {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("runtest: couldn't read the fixture: %v", err)
+	}
+	return JSON(data)
+}
+
+// Version returns a call that responds to `semgrep --version`.
+func Version(version string) Call {
+	return Call{Stdout: []byte(version + "\n")}.Expect(run.Semgrep, run.VersionSwitch)
+}
+
+// NotFound returns a call that simulates a missing Semgrep binary.
+func NotFound() Call {
+	return Call{Err: &exec.Error{Name: run.Semgrep, Err: exec.ErrNotFound}}
+}
+
+// ----------
+
+// Runner is a fake run.Runner that replays scripted calls in order. It's safe
+// for concurrent use.
+type Runner struct {
+	mu       sync.Mutex
+	calls    []Call
+	next     int
+	received []run.Invocation
+	failures []string
+}
+
+// New returns a Runner that replays the calls in order.
+func New(calls ...Call) *Runner {
+	return &Runner{calls: calls}
+}
+
+// Run implements run.Runner.
+func (r *Runner) Run(ctx context.Context, inv *run.Invocation) (int, error) {
+	r.mu.Lock()
+	// Record everything except the writers.
+	r.received = append(r.received, run.Invocation{
+		Args: append([]string(nil), inv.Args...),
+		Dir:  inv.Dir,
+		Env:  append([]string(nil), inv.Env...),
+	})
+	if r.next >= len(r.calls) {
+		r.failures = append(r.failures, fmt.Sprintf("unexpected call: %s", strings.Join(inv.Args, " ")))
+		r.mu.Unlock()
+		return -1, fmt.Errorf("runtest: unexpected call: %s", strings.Join(inv.Args, " "))
+	}
+	call := r.calls[r.next]
+	r.next++
+	if call.WantArgs != nil && !reflect.DeepEqual(call.WantArgs, inv.Args) {
+		r.failures = append(r.failures, fmt.Sprintf("call %d:\n got: %q\nwant: %q", r.next, inv.Args, call.WantArgs))
+	}
+	r.mu.Unlock()
+
+	if call.Err != nil {
+		return -1, call.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+	if _, err := inv.Stdout.Write(call.Stdout); err != nil {
+		return -1, err
+	}
+	if _, err := inv.Stderr.Write([]byte(call.Stderr)); err != nil {
+		return -1, err
+	}
+	if call.Hang {
+		<-ctx.Done()
+		return -1, ctx.Err()
+	}
+	return call.ExitCode, nil
+}
+
+// Invocations returns every invocation the runner received. Stdout and Stderr
+// are not set.
+func (r *Runner) Invocations() []run.Invocation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]run.Invocation(nil), r.received...)
+}
+
+// Check reports argv mismatches, unexpected calls, and scripted calls that were
+// never made.
+func (r *Runner) Check(t testing.TB) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.failures {
+		t.Errorf("runtest: %s", f)
+	}
+	if r.next < len(r.calls) {
+		t.Errorf("runtest: %d of %d scripted calls were not made", len(r.calls)-r.next, len(r.calls))
+	}
+}
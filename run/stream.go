@@ -0,0 +1,85 @@
+package run
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/parsiya/semgrep_go/output"
+)
+
+// RunJSONStream runs Semgrep and decodes the JSON output from its stdout while
+// Semgrep is printing it. fn is called with each result in order. The whole
+// output is never in memory so it can be used for very large scans.
+//
+// The returned output has everything except the results. If fn returns an
+// error, Semgrep is killed and the error is returned.
+//
+// Errors are the same as RunJSONContext, but the stdout in *ExecError and
+// *ContextError is always empty. If Semgrep exits with a non-zero exit code
+// after printing the output (e.g., findings with `--error`), the results are
+// passed to fn and the output is returned with the *ExecError.
+//
+// WarnVersion and LenientVersion both log a warning after the scan if the
+// version is outside output.Compatibility. The output is not deserialized
+// leniently.
+func (o *Options) RunJSONStream(ctx context.Context, fn func(output.CliMatch) error) (output.Output, error) {
+	o.Output = JSON
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := internalRun(ctx, o, pw)
+		// The decoder sees the end of the output, the error is returned below.
+		pw.Close()
+		done <- err
+	}()
+
+	dec := output.NewDecoder(pr)
+	var fnErr error
+	for dec.Next() {
+		if fnErr = fn(dec.Result()); fnErr != nil {
+			// Stop Semgrep.
+			cancel()
+			break
+		}
+	}
+	// Read the rest of stdout so Semgrep is not blocked on the pipe.
+	io.Copy(io.Discard, pr)
+	runErr := <-done
+
+	out := dec.Output()
+	switch {
+	case fnErr != nil:
+		return out, fnErr
+	case runErr != nil:
+		return out, runErr
+	case dec.Err() != nil:
+		return out, dec.Err()
+	}
+	o.warnVersion(out)
+	return out, nil
+}
+
+// warnVersion logs a warning if the version in the output is outside
+// output.Compatibility and the VersionPolicy is WarnVersion or LenientVersion.
+func (o *Options) warnVersion(out output.Output) {
+	if o.VersionPolicy != WarnVersion && o.VersionPolicy != LenientVersion {
+		return
+	}
+	if out.Version == nil {
+		log.Printf("Couldn't find the Semgrep version in the output")
+		return
+	}
+	version, err := out.Version.Parse()
+	if err != nil {
+		log.Printf("Couldn't find the Semgrep version in the output")
+		return
+	}
+	if status := output.Compatibility.Check(version); !status.Compatible() {
+		log.Printf("Warning: %v", &VersionError{Version: version, Status: status})
+	}
+}
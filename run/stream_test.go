@@ -0,0 +1,107 @@
+package run_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/parsiya/semgrep_go/output"
+	"github.com/parsiya/semgrep_go/run"
+	"github.com/parsiya/semgrep_go/run/runtest"
+)
+
+const juiceShopFixture = "../output/test/juice-shop-1.42.0.json"
+
+func TestOptions_RunJSONStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     runtest.Call
+		wantErr  bool
+		wantExec bool
+	}{
+		{
+			name: "success",
+			call: runtest.FromFile(t, juiceShopFixture),
+		},
+		{
+			name:     "findings with --error",
+			call:     runtest.FromFile(t, juiceShopFixture).WithExitCode(1),
+			wantErr:  true,
+			wantExec: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runtest.New(tt.call)
+			opts := run.DefaultOptions([]string{"p/default"}, []string{"juice-shop"})
+			opts.Runner = fake
+
+			count := 0
+			out, err := opts.RunJSONStream(context.Background(), func(c output.CliMatch) error {
+				count++
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunJSONStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var execErr *run.ExecError
+			if errors.As(err, &execErr) != tt.wantExec {
+				t.Errorf("RunJSONStream() error = %v, want *ExecError: %v", err, tt.wantExec)
+			}
+			if count != 67 {
+				t.Errorf("got %d results, want 67", count)
+			}
+			if len(out.Paths.Scanned) != 977 {
+				t.Errorf("got %d scanned paths, want 977", len(out.Paths.Scanned))
+			}
+			fake.Check(t)
+		})
+	}
+}
+
+func TestOptions_RunJSONStream_Stop(t *testing.T) {
+	// Semgrep runs until it's killed.
+	call := runtest.FromFile(t, juiceShopFixture)
+	call.Hang = true
+	fake := runtest.New(call)
+	opts := run.DefaultOptions([]string{"p/default"}, []string{"juice-shop"})
+	opts.Runner = fake
+
+	stop := errors.New("stop")
+	count := 0
+	start := time.Now()
+	_, err := opts.RunJSONStream(context.Background(), func(c output.CliMatch) error {
+		count++
+		if count == 3 {
+			return stop
+		}
+		return nil
+	})
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("RunJSONStream() returned after %v, Semgrep was not killed", elapsed)
+	}
+	if !errors.Is(err, stop) {
+		t.Errorf("RunJSONStream() error = %v, want %v", err, stop)
+	}
+	if count != 3 {
+		t.Errorf("got %d results, want 3", count)
+	}
+	fake.Check(t)
+}
+
+func TestOptions_RunJSONStream_NotFound(t *testing.T) {
+	fake := runtest.New(runtest.NotFound())
+	opts := run.DefaultOptions([]string{"p/default"}, []string{"."})
+	opts.Runner = fake
+
+	_, err := opts.RunJSONStream(context.Background(), func(c output.CliMatch) error {
+		t.Errorf("fn was called")
+		return nil
+	})
+	var execErr *run.ExecError
+	if !errors.As(err, &execErr) {
+		t.Errorf("RunJSONStream() error = %v, want *ExecError", err)
+	}
+	fake.Check(t)
+}
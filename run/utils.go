@@ -1,6 +1,10 @@
 package run
 
-import "os"
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
 
 // toSwitch returns "--[value]".
 func toSwitch(value string) string {
@@ -9,17 +13,36 @@ func toSwitch(value string) string {
 
 const TempFilePrefix = "semgrep"
 
-// createTempFile writes the data to a temporary file and returns the handle.
-// Note: We need to delete the file ourselves.
-func createTempFile(data string) (string, error) {
+// createTempDir creates a private temp directory. os.MkdirTemp creates it with
+// 0700 so only the current user can access the files inside.
+func createTempDir() (string, error) {
+	return os.MkdirTemp("", TempFilePrefix)
+}
 
-	// Create the temp file with the prefix.
-	f, err := os.CreateTemp("", TempFilePrefix)
+// writeTempFile writes the data to a new file in dir and returns the path. The
+// file is only readable by the current user and is closed before returning.
+func writeTempFile(dir, name string, data []byte) (string, error) {
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
 	if err != nil {
 		return "", err
 	}
-
 	// Write to file.
-	_, err = f.WriteString(data)
-	return f.Name(), err
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// removeTempDir deletes the temp directory. If keep is true, the directory is
+// kept and the path is logged for debugging.
+func removeTempDir(dir string, keep bool) {
+	if keep {
+		log.Printf("Keeping the Semgrep temp directory: %s", dir)
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("Couldn't delete the Semgrep temp directory %s: %v", dir, err)
+	}
 }
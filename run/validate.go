@@ -0,0 +1,125 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/parsiya/semgrep_go/output"
+	"github.com/parsiya/semgrep_go/output/enum"
+)
+
+// The validate switch.
+const ValidateSwitch = "--validate"
+
+// RuleDiagnostic is a problem in a rule reported by `semgrep --validate`.
+type RuleDiagnostic struct {
+	// The rule ID. Empty if Semgrep couldn't find it (e.g., invalid YAML).
+	RuleID string
+
+	// The rule file. For string rules, it's the merged temp file which is
+	// deleted after validation.
+	File string
+
+	// The position of the problem in the rule file. Zero if Semgrep didn't
+	// report it.
+	Start output.Position
+	End   output.Position
+
+	// The path to the problem in the YAML document. E.g., ["rules", "0",
+	// "patterns"]. Empty if Semgrep didn't report it.
+	YAMLPath []string
+
+	// Error level (e.g., "error" or "warn") and message.
+	Level   enum.ErrorLevel
+	Message string
+
+	// The original error.
+	Err output.CliError
+}
+
+// String returns the diagnostic in "file:line:col: [rule] message" format.
+func (d RuleDiagnostic) String() string {
+	rule := ""
+	if d.RuleID != "" {
+		rule = "[" + d.RuleID + "] "
+	}
+	return fmt.Sprintf("%s:%d:%d: %s%s", d.File, d.Start.Line, d.Start.Col, rule, d.Message)
+}
+
+// ValidateRules runs `semgrep --validate` with the rules in o.Rules. Rule files
+// and string rules (see Options.StringRule) are supported. Metrics are always
+// off. Paths, Output, Verbosity, and the typed scan switches are ignored, Extra
+// is passed as-is.
+//
+// Invalid rules are returned as diagnostics, not as an error. The error is set
+// if Semgrep couldn't validate the rules.
+func ValidateRules(ctx context.Context, o *Options) ([]RuleDiagnostic, error) {
+	// Store string rules in a temp directory.
+	ruleFile, cleanup, err := o.writeRules()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	opts := []string{ValidateSwitch, JSON.String(), MetricsOff}
+	opts = append(opts, o.configSwitches(ruleFile)...)
+	opts = append(opts, o.Extra...)
+
+	var cliErrors []output.CliError
+	stdout, err := o.execute(ctx, opts)
+	if err != nil {
+		// Semgrep exits with an error for invalid rules but the errors are in
+		// the JSON output.
+		var execErr *ExecError
+		if !errors.As(err, &execErr) || len(execErr.Errors) == 0 {
+			return nil, err
+		}
+		cliErrors = execErr.Errors
+	} else {
+		cliErrors = parseCliErrors(stdout)
+	}
+
+	diags := make([]RuleDiagnostic, 0, len(cliErrors))
+	for _, e := range cliErrors {
+		diags = append(diags, newRuleDiagnostic(e))
+	}
+	return diags, nil
+}
+
+// newRuleDiagnostic converts a CliError to a RuleDiagnostic.
+func newRuleDiagnostic(e output.CliError) RuleDiagnostic {
+	d := RuleDiagnostic{Err: e}
+	if e.RuleId != nil {
+		d.RuleID = string(*e.RuleId)
+	}
+	if e.Path != nil {
+		d.File = string(*e.Path)
+	}
+	d.Level = e.ErrorLevel()
+
+	// Prefer the long message.
+	switch {
+	case e.LongMsg != nil && *e.LongMsg != "":
+		d.Message = *e.LongMsg
+	case e.Message != nil:
+		d.Message = *e.Message
+	case e.ShortMsg != nil:
+		d.Message = *e.ShortMsg
+	}
+
+	// The first span has the position. Spans in rule files have the YAML
+	// position in config_start and config_end.
+	if len(e.Spans) > 0 {
+		span := e.Spans[0]
+		if d.File == "" {
+			d.File = string(span.File)
+		}
+		d.Start, d.End = span.Start, span.End
+		if span.ConfigStart != nil && span.ConfigEnd != nil {
+			d.Start, d.End = *span.ConfigStart, *span.ConfigEnd
+		}
+		d.YAMLPath = span.ConfigPath
+	}
+	return d
+}
@@ -0,0 +1,92 @@
+package run_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/run"
+	"github.com/parsiya/semgrep_go/run/runtest"
+)
+
+const invalidRuleJSON = `{
+  "errors": [
+    {
+      "code": 7,
+      "level": "error",
+      "type": "Invalid rule schema",
+      "rule_id": "bad-rule",
+      "message": "Invalid rule schema",
+      "long_msg": "One of these properties is missing: 'pattern', 'patterns'",
+      "path": "rules/bad.yaml",
+      "spans": [
+        {
+          "file": "rules/bad.yaml",
+          "start": {"line": 2, "col": 5, "offset": 10},
+          "end": {"line": 6, "col": 1, "offset": 90},
+          "config_path": ["rules", "0"],
+          "config_start": {"line": 3, "col": 7},
+          "config_end": {"line": 5, "col": 20}
+        }
+      ]
+    }
+  ],
+  "results": [],
+  "paths": {"scanned": []}
+}`
+
+func TestValidateRules(t *testing.T) {
+	fake := runtest.New(
+		runtest.JSON([]byte(invalidRuleJSON)).WithExitCode(7).
+			Expect("semgrep", "--validate", "--json", "--metrics=off", "--config", "rules/bad.yaml"),
+	)
+	opts := &run.Options{Rules: []string{"rules/bad.yaml"}, Runner: fake}
+	// Metrics are always off.
+	opts.EnableMetrics()
+
+	diags, err := run.ValidateRules(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("ValidateRules() error = %v", err)
+	}
+	fake.Check(t)
+
+	if len(diags) != 1 {
+		t.Fatalf("len(ValidateRules()) = %d, want %d", len(diags), 1)
+	}
+	d := diags[0]
+	if d.RuleID != "bad-rule" || d.File != "rules/bad.yaml" || d.Level != "error" {
+		t.Errorf("ValidateRules() = %+v", d)
+	}
+	if d.Start.Line != 3 || d.Start.Col != 7 || d.End.Line != 5 {
+		t.Errorf("ValidateRules() position = %v - %v, want the config position", d.Start, d.End)
+	}
+	want := "rules/bad.yaml:3:7: [bad-rule] One of these properties is missing: 'pattern', 'patterns'"
+	if d.String() != want {
+		t.Errorf("RuleDiagnostic.String() = %q, want %q", d.String(), want)
+	}
+}
+
+func TestValidateRules_StringRule(t *testing.T) {
+	// The rule file is a temp file, don't check the argv.
+	fake := runtest.New(runtest.JSON([]byte(`{"errors": [], "results": [], "paths": {"scanned": []}}`)))
+	opts := &run.Options{
+		Rules:  []string{"id: ok\npattern: foo()\nmessage: foo\nlanguages: [go]\nseverity: INFO\n"},
+		Runner: fake,
+	}
+	opts.StringRule()
+
+	diags, err := run.ValidateRules(context.Background(), opts)
+	if err != nil || len(diags) != 0 {
+		t.Errorf("ValidateRules() = %v, %v, want no diagnostics", diags, err)
+	}
+	fake.Check(t)
+}
+
+func TestValidateRules_NotFound(t *testing.T) {
+	fake := runtest.New(runtest.NotFound())
+	opts := &run.Options{Rules: []string{"rules/"}, Runner: fake}
+
+	if _, err := run.ValidateRules(context.Background(), opts); !errors.Is(err, run.ReasonNotFound) {
+		t.Errorf("ValidateRules() error = %v, want %v", err, run.ReasonNotFound)
+	}
+}
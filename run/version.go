@@ -0,0 +1,113 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/parsiya/semgrep_go/output"
+)
+
+// VersionPolicy is what to do when the Semgrep version is outside the range the
+// output structs were tested with. See output.Compatibility.
+type VersionPolicy int
+
+const (
+	// Don't check the version. This is the default.
+	IgnoreVersion VersionPolicy = iota
+	// Log a warning and deserialize the output as usual.
+	WarnVersion
+	// Run `semgrep --version` before the scan and return a *VersionError
+	// without scanning if the version is outside the range.
+	RefuseVersion
+	// Deserialize the output with output.DeserializeLenient if the version is
+	// outside the range. Elements that don't match the structs are skipped and
+	// logged instead of failing the whole output.
+	LenientVersion
+)
+
+// VersionError is returned with RefuseVersion when the Semgrep version is
+// outside output.Compatibility.
+type VersionError struct {
+	Version output.SemVer
+	Status  output.VersionStatus
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("semgrep %s is %s for the output structs, tested versions are %s to %s",
+		e.Version, e.Status, output.Compatibility.Min(), output.Compatibility.Max())
+}
+
+// SemVer returns the parsed version of Semgrep executed with these options.
+func (o *Options) SemVer() (output.SemVer, error) {
+	return o.semVer(context.Background())
+}
+
+// semVer returns the parsed version with a context.
+func (o *Options) semVer(ctx context.Context) (output.SemVer, error) {
+	version, err := o.version(ctx)
+	if err != nil {
+		return output.SemVer{}, err
+	}
+	// Only use the first line in case Semgrep prints more.
+	first := strings.SplitN(version, "\n", 2)[0]
+	return output.ParseVersion(first)
+}
+
+// checkVersion runs `semgrep --version` and returns a *VersionError if the
+// version is outside output.Compatibility.
+func (o *Options) checkVersion(ctx context.Context) error {
+	version, err := o.semVer(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't check the Semgrep version: %w", err)
+	}
+	if status := output.Compatibility.Check(version); !status.Compatible() {
+		return &VersionError{Version: version, Status: status}
+	}
+	return nil
+}
+
+// deserialize deserializes the JSON output based on the VersionPolicy.
+func (o *Options) deserialize(data []byte) (output.Output, error) {
+	if o.VersionPolicy != WarnVersion && o.VersionPolicy != LenientVersion {
+		return output.Deserialize(data)
+	}
+
+	// Get the version from the output.
+	version, ok := peekVersion(data)
+	if !ok {
+		log.Printf("Couldn't find the Semgrep version in the output")
+		return output.Deserialize(data)
+	}
+	status := output.Compatibility.Check(version)
+	if status.Compatible() {
+		return output.Deserialize(data)
+	}
+
+	if o.VersionPolicy == WarnVersion {
+		log.Printf("Warning: %v", &VersionError{Version: version, Status: status})
+		return output.Deserialize(data)
+	}
+
+	// LenientVersion.
+	out, skipped, err := output.DeserializeLenient(data)
+	for _, s := range skipped {
+		log.Printf("Skipped an element in the output of semgrep %s: %v", version, s)
+	}
+	return out, err
+}
+
+// peekVersion returns the version in the JSON output without deserializing
+// the rest.
+func peekVersion(data []byte) (output.SemVer, bool) {
+	var partial struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &partial); err != nil || partial.Version == "" {
+		return output.SemVer{}, false
+	}
+	version, err := output.ParseVersion(partial.Version)
+	return version, err == nil
+}
@@ -0,0 +1,91 @@
+package run_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/parsiya/semgrep_go/output"
+	"github.com/parsiya/semgrep_go/run"
+	"github.com/parsiya/semgrep_go/run/runtest"
+)
+
+// A result without the required `path`.
+const brokenOutput = `{
+	"version": "%s",
+	"errors": [],
+	"results": [{"check_id": "a", "start": {"line": 1, "col": 1}, "end": {"line": 1, "col": 2}, "extra": {}}],
+	"paths": {"scanned": ["a.go"]}
+}`
+
+func TestOptions_VersionPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     run.VersionPolicy
+		calls      []runtest.Call
+		wantStatus output.VersionStatus
+		wantErr    bool
+		wantPaths  int
+	}{
+		{
+			name:   "refuse too new",
+			policy: run.RefuseVersion,
+			// Only `semgrep --version` is executed.
+			calls:      []runtest.Call{runtest.Version("1.99.0")},
+			wantStatus: output.VersionTooNew,
+			wantErr:    true,
+		},
+		{
+			name:   "refuse compatible",
+			policy: run.RefuseVersion,
+			calls: []runtest.Call{
+				runtest.Version("1.52.0"),
+				runtest.JSON([]byte(`{"errors": [], "results": [], "paths": {"scanned": ["a.go"]}}`)),
+			},
+			wantPaths: 1,
+		},
+		{
+			name:    "warn",
+			policy:  run.WarnVersion,
+			calls:   []runtest.Call{runtest.JSON([]byte(fmtVersion("1.99.0")))},
+			wantErr: true,
+		},
+		{
+			name:      "lenient too new",
+			policy:    run.LenientVersion,
+			calls:     []runtest.Call{runtest.JSON([]byte(fmtVersion("1.99.0")))},
+			wantPaths: 1,
+		},
+		{
+			name:    "lenient compatible",
+			policy:  run.LenientVersion,
+			calls:   []runtest.Call{runtest.JSON([]byte(fmtVersion("1.52.0")))},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runtest.New(tt.calls...)
+			opts := run.DefaultOptions([]string{"p/default"}, []string{"."})
+			opts.Runner = fake
+			opts.VersionPolicy = tt.policy
+
+			out, err := opts.RunJSON()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var versionErr *run.VersionError
+			if errors.As(err, &versionErr) && versionErr.Status != tt.wantStatus {
+				t.Errorf("VersionError.Status = %v, want %v", versionErr.Status, tt.wantStatus)
+			}
+			if len(out.Paths.Scanned) != tt.wantPaths {
+				t.Errorf("len(RunJSON().Paths.Scanned) = %d, want %d", len(out.Paths.Scanned), tt.wantPaths)
+			}
+			fake.Check(t)
+		})
+	}
+}
+
+func fmtVersion(version string) string {
+	return fmt.Sprintf(brokenOutput, version)
+}